@@ -0,0 +1,477 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/DrJosh9000/vec"
+)
+
+// Manifest lists the assets of a game. File names are relative to the
+// manifest. It is loaded from JSON with LoadAssets, for example:
+//
+//	{
+//	  "images": {"player": "img/player.png", "tiles": "img/tiles.png", "map": "img/map.png"},
+//	  "sheets": {"player": {"image": "player", "frame_size": [16, 24], "frames": 4, "duration": 3, "offset": [8, 22]}},
+//	  "doodads": {"tree": {"sheet": "trees", "frame": 0, "offset": [16, 40], "ul": [12, 36], "dr": [20, 40]}},
+//	  "fonts": {"body": {"bmfont": "fonts/body.fnt"}},
+//	  "levels": {"town": "levels/town.json"}
+//	}
+type Manifest struct {
+	Images  map[string]string    `json:"images,omitempty"` // image key to PNG file
+	Sheets  map[string]SheetDef  `json:"sheets,omitempty"`
+	Doodads map[string]DoodadDef `json:"doodads,omitempty"`
+	Fonts   map[string]FontDef   `json:"fonts,omitempty"`
+	Levels  map[string]string    `json:"levels,omitempty"` // level name to LevelDef JSON file
+}
+
+// SheetDef describes a Sheet. Either Import names an Aseprite or TexturePacker
+// JSON file (see ImportSheet), or FrameInfos are given, or Frames frames all
+// with the same Duration and Offset (see BasicFrameInfos).
+type SheetDef struct {
+	Image       string `json:"image"` // registered image key, such as from Manifest.Images
+	Import      string `json:"import,omitempty"`
+	FrameMillis int    `json:"frame_ms,omitempty"` // for Import; see ImportSheet
+
+	FrameSize  [2]int         `json:"frame_size"`
+	Columns    int            `json:"columns,omitempty"`
+	Frames     int            `json:"frames,omitempty"`
+	Duration   int            `json:"duration,omitempty"`
+	Offset     [2]int         `json:"offset,omitempty"`
+	FrameInfos []FrameInfoDef `json:"frame_infos,omitempty"`
+}
+
+// FrameInfoDef describes a FrameInfo. If Next is omitted, it is the following
+// frame (wrapping around to the first).
+type FrameInfoDef struct {
+	Duration int    `json:"duration,omitempty"`
+	Next     *int   `json:"next,omitempty"`
+	Offset   [2]int `json:"offset,omitempty"`
+}
+
+// DoodadDef describes a BaseDoodad.
+type DoodadDef struct {
+	Sheet  string `json:"sheet"` // key in Manifest.Sheets
+	Frame  int    `json:"frame,omitempty"`
+	Offset [2]int `json:"offset,omitempty"`
+	UL     [2]int `json:"ul,omitempty"`
+	DR     [2]int `json:"dr,omitempty"`
+}
+
+// FontDef describes a Font. Page images of a BMFont are relative to the
+// descriptor file.
+type FontDef struct {
+	BMFont string `json:"bmfont"`
+}
+
+// LevelDef is the file format of a level. Images are registered image keys,
+// such as from Manifest.Images; the maps are paletted PNGs (see ImageAsMap).
+type LevelDef struct {
+	Tileset     string      `json:"tileset"`
+	Blockset    string      `json:"blockset"`
+	TileSize    int         `json:"tile_size"`
+	BlockHeight int         `json:"block_height"`
+	TileMap     string      `json:"tile_map"`
+	BlockMap    string      `json:"block_map"`
+	Tiles       []TileInfo  `json:"tiles"`
+	Blocks      []TileInfo  `json:"blocks"`
+	Doodads     []DoodadRef `json:"doodads,omitempty"`
+}
+
+// DoodadRef places a doodad from Manifest.Doodads in a level.
+type DoodadRef struct {
+	Doodad string `json:"doodad"`
+	Pos    [2]int `json:"pos"`
+}
+
+// Assets are the things loaded by LoadAssets. Images are registered with
+// RegisterImage rather than kept here.
+type Assets struct {
+	Sheets  map[string]*Sheet
+	Doodads map[string]*BaseDoodad
+	Fonts   map[string]Font
+	Levels  map[string]*Level
+
+	fsys     fs.FS
+	manifest string
+	mtimes   map[string]time.Time // of each file read, for Reload
+	images   map[string]string    // files of the images registered, by key
+}
+
+// LevelNamed returns a loaded level, so that a Game can implement LevelNamer
+// by embedding *Assets.
+func (a *Assets) LevelNamed(name string) (*Level, error) {
+	l, ok := a.Levels[name]
+	if !ok {
+		return nil, fmt.Errorf("no level %q in assets", name)
+	}
+	return l, nil
+}
+
+// assetLoader keeps track of where it is, for error messages.
+type assetLoader struct {
+	fsys     fs.FS
+	manifest string
+	m        *Manifest
+	a        *Assets
+	reload   bool // only read changed images, update in place, and skip fonts
+}
+
+// LoadAssets reads the manifest file from fsys, registers the images, and
+// loads the sheets, doodads, fonts and levels it lists. Errors name the
+// manifest entry at fault.
+func LoadAssets(fsys fs.FS, manifest string) (*Assets, error) {
+	a := &Assets{
+		Sheets:   make(map[string]*Sheet),
+		Doodads:  make(map[string]*BaseDoodad),
+		Fonts:    make(map[string]Font),
+		Levels:   make(map[string]*Level),
+		fsys:     fsys,
+		manifest: manifest,
+		mtimes:   make(map[string]time.Time),
+		images:   make(map[string]string),
+	}
+	if err := a.load(false); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Reload loads the assets again if any of their files have changed since they
+// were read, and reports whether they had. Changed images are replaced, and
+// sheets, doodads and levels are updated in place, so things using them see
+// the changes. Images no longer in the manifest are unloaded (see
+// UnloadImage). Fonts are not reloaded.
+func (a *Assets) Reload() (bool, error) {
+	changed := false
+	for file := range a.mtimes {
+		if a.modified(file) {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	return true, a.load(true)
+}
+
+// modified reports whether a file has changed (or gone) since it was read.
+func (a *Assets) modified(file string) bool {
+	fi, err := fs.Stat(a.fsys, file)
+	if err != nil {
+		return true
+	}
+	t, ok := a.mtimes[file]
+	return !ok || !fi.ModTime().Equal(t)
+}
+
+// readFile reads a file, noting its modification time.
+func (a *Assets) readFile(file string) ([]byte, error) {
+	fi, err := fs.Stat(a.fsys, file)
+	if err != nil {
+		return nil, err
+	}
+	data, err := fs.ReadFile(a.fsys, file)
+	if err != nil {
+		return nil, err
+	}
+	a.mtimes[file] = fi.ModTime()
+	return data, nil
+}
+
+func (a *Assets) load(reload bool) error {
+	data, err := a.readFile(a.manifest)
+	if err != nil {
+		return fmt.Errorf("reading manifest: %v", err)
+	}
+	m := new(Manifest)
+	if err := json.Unmarshal(data, m); err != nil {
+		return fmt.Errorf("%s: %v", a.manifest, err)
+	}
+	l := &assetLoader{
+		fsys:     a.fsys,
+		manifest: a.manifest,
+		m:        m,
+		a:        a,
+		reload:   reload,
+	}
+	for _, k := range sortedKeys(m.Images) {
+		if err := l.image(k); err != nil {
+			return l.errorf("image", k, err)
+		}
+	}
+	for _, k := range sortedKeys(m.Sheets) {
+		if err := l.sheet(k); err != nil {
+			return l.errorf("sheet", k, err)
+		}
+	}
+	for _, k := range sortedKeys(m.Doodads) {
+		if err := l.doodad(k); err != nil {
+			return l.errorf("doodad", k, err)
+		}
+	}
+	for _, k := range sortedKeys(m.Fonts) {
+		if l.reload {
+			break
+		}
+		if err := l.font(k); err != nil {
+			return l.errorf("font", k, err)
+		}
+	}
+	for _, k := range sortedKeys(m.Levels) {
+		if err := l.level(k); err != nil {
+			return l.errorf("level", k, err)
+		}
+	}
+
+	// Everything loaded without them, so release images no longer listed.
+	for k, file := range a.images {
+		if _, ok := m.Images[k]; ok {
+			continue
+		}
+		UnloadImage(k)
+		delete(a.images, k)
+		delete(a.mtimes, file)
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of a map from strings, in order, so assets load
+// (and fail) in a repeatable order.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]string:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]SheetDef:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]DoodadDef:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]FontDef:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (l *assetLoader) errorf(kind, key string, err error) error {
+	return fmt.Errorf("%s: %s %q: %v", l.manifest, kind, key, err)
+}
+
+// path resolves a file name relative to the manifest.
+func (l *assetLoader) path(file string) string {
+	return path.Join(path.Dir(l.manifest), file)
+}
+
+func (l *assetLoader) image(key string) error {
+	file := l.path(l.m.Images[key])
+	_, known := allData[key]
+	if l.reload && known && !l.a.modified(file) {
+		return nil
+	}
+	data, err := l.a.readFile(file)
+	if err != nil {
+		return err
+	}
+	if l.reload && known {
+		replaceImage(key, data)
+	} else {
+		RegisterImage(key, data)
+	}
+	l.a.images[key] = file
+	return nil
+}
+
+// hasImage reports whether an image is registered, and (if these assets
+// registered it) is still in the manifest.
+func (l *assetLoader) hasImage(key string) bool {
+	if _, ok := allData[key]; !ok {
+		return false
+	}
+	if _, ours := l.a.images[key]; ours {
+		_, listed := l.m.Images[key]
+		return listed
+	}
+	return true
+}
+
+func (l *assetLoader) sheet(key string) error {
+	d := l.m.Sheets[key]
+	if !l.hasImage(d.Image) {
+		return fmt.Errorf("unknown image %q", d.Image)
+	}
+	if d.Import != "" {
+		data, err := l.a.readFile(l.path(d.Import))
+		if err != nil {
+			return err
+		}
+		s, err := ImportSheet(bytes.NewReader(data), d.Image, d.FrameMillis)
+		if err != nil {
+			return fmt.Errorf("%s: %v", d.Import, err)
+		}
+		return l.setSheet(key, s)
+	}
+	s := &Sheet{
+		Key:       d.Image,
+		Columns:   d.Columns,
+		FrameSize: vec.I2{d.FrameSize[0], d.FrameSize[1]},
+	}
+	if s.FrameSize.X <= 0 || s.FrameSize.Y <= 0 {
+		return fmt.Errorf("frame_size %v is not positive", d.FrameSize)
+	}
+	switch {
+	case len(d.FrameInfos) > 0:
+		n := len(d.FrameInfos)
+		s.FrameInfos = make([]FrameInfo, n)
+		for i, fi := range d.FrameInfos {
+			next := (i + 1) % n
+			if fi.Next != nil {
+				if next = *fi.Next; next < 0 || next >= n {
+					return fmt.Errorf("frame_infos[%d]: next frame %d out of range [0, %d)", i, next, n)
+				}
+			}
+			s.FrameInfos[i] = FrameInfo{
+				Duration: fi.Duration,
+				Next:     next,
+				Offset:   vec.I2{fi.Offset[0], fi.Offset[1]},
+			}
+		}
+	case d.Frames > 0:
+		s.FrameInfos = BasicFrameInfos(d.Frames, d.Duration, vec.I2{d.Offset[0], d.Offset[1]})
+	default:
+		return fmt.Errorf("no frames or frame_infos")
+	}
+	return l.setSheet(key, s)
+}
+
+// setSheet adds the sheet, or updates the existing one in place.
+func (l *assetLoader) setSheet(key string, s *Sheet) error {
+	if old := l.a.Sheets[key]; old != nil {
+		*old = *s
+		return nil
+	}
+	l.a.Sheets[key] = s
+	return nil
+}
+
+func (l *assetLoader) doodad(key string) error {
+	d := l.m.Doodads[key]
+	s, ok := l.a.Sheets[d.Sheet]
+	if !ok {
+		return fmt.Errorf("unknown sheet %q", d.Sheet)
+	}
+	if d.Frame < 0 || d.Frame >= len(s.FrameInfos) {
+		return fmt.Errorf("frame %d out of range [0, %d)", d.Frame, len(s.FrameInfos))
+	}
+	bd := &BaseDoodad{
+		SheetFrame: &SheetFrame{s, d.Frame},
+		Offset:     vec.I2{d.Offset[0], d.Offset[1]},
+		UL:         vec.I2{d.UL[0], d.UL[1]},
+		DR:         vec.I2{d.DR[0], d.DR[1]},
+	}
+	if old := l.a.Doodads[key]; old != nil {
+		*old = *bd
+		return nil
+	}
+	l.a.Doodads[key] = bd
+	return nil
+}
+
+func (l *assetLoader) font(key string) error {
+	d := l.m.Fonts[key]
+	if d.BMFont == "" {
+		return fmt.Errorf("no bmfont file")
+	}
+	file := l.path(d.BMFont)
+	desc, err := l.a.readFile(file)
+	if err != nil {
+		return err
+	}
+	f, err := LoadBMFont(key, desc, func(page string) ([]byte, error) {
+		return fs.ReadFile(l.fsys, path.Join(path.Dir(file), page))
+	})
+	if err != nil {
+		return err
+	}
+	l.a.Fonts[key] = f
+	return nil
+}
+
+func (l *assetLoader) level(name string) error {
+	data, err := l.a.readFile(l.path(l.m.Levels[name]))
+	if err != nil {
+		return err
+	}
+	d := new(LevelDef)
+	if err := json.Unmarshal(data, d); err != nil {
+		return fmt.Errorf("%s: %v", l.m.Levels[name], err)
+	}
+	for _, k := range []string{d.Tileset, d.Blockset, d.TileMap, d.BlockMap} {
+		if !l.hasImage(k) {
+			return fmt.Errorf("unknown image %q", k)
+		}
+	}
+	tm, size, err := ImageAsMap(d.TileMap)
+	if err != nil {
+		return fmt.Errorf("tile map: %v", err)
+	}
+	bm, bsize, err := ImageAsMap(d.BlockMap)
+	if err != nil {
+		return fmt.Errorf("block map: %v", err)
+	}
+	if bsize != size {
+		return fmt.Errorf("block map size %v differs from tile map size %v", bsize, size)
+	}
+	lv := &Level{
+		MapSize:     size,
+		TileMap:     tm,
+		BlockMap:    bm,
+		TileInfos:   d.Tiles,
+		BlockInfos:  d.Blocks,
+		TilesetKey:  d.Tileset,
+		BlocksetKey: d.Blockset,
+		TileSize:    d.TileSize,
+		BlockHeight: d.BlockHeight,
+	}
+	for i, r := range d.Doodads {
+		bd, ok := l.a.Doodads[r.Doodad]
+		if !ok {
+			return fmt.Errorf("doodads[%d]: unknown doodad %q", i, r.Doodad)
+		}
+		lv.Doodads = append(lv.Doodads, &Doodad{P: vec.I2{r.Pos[0], r.Pos[1]}, BaseDoodad: bd})
+	}
+	if old := l.a.Levels[name]; old != nil {
+		*old = *lv
+		return nil
+	}
+	l.a.Levels[name] = lv
+	return nil
+}
@@ -0,0 +1,197 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DrJosh9000/vec"
+)
+
+const testManifest = `{
+  "images": {"hero": "img/hero.png", "tiles": "img/tiles.png", "map": "img/map.png"},
+  "sheets": {
+    "hero": {"image": "hero", "frame_size": [4, 4], "frames": 2, "duration": 3, "offset": [2, 3]},
+    "tree": {"image": "tiles", "frame_size": [8, 8], "frame_infos": [{"duration": -1, "next": 0}]}
+  },
+  "doodads": {"tree": {"sheet": "tree", "offset": [4, 8], "ul": [2, 6], "dr": [6, 8]}},
+  "levels": {"town": "levels/town.json"}
+}`
+
+const testLevel = `{
+  "tileset": "tiles", "blockset": "tiles", "tile_size": 8, "block_height": 8,
+  "tile_map": "map", "block_map": "map",
+  "tiles": [{"name": "grass"}, {"name": "water", "blocking": true}],
+  "blocks": [{"name": "none"}],
+  "doodads": [{"doodad": "tree", "pos": [12, 20]}]
+}`
+
+// testAssetFS returns a file system with a manifest, images and a level.
+func testAssetFS(t *testing.T) fstest.MapFS {
+	pal := image.NewPaletted(image.Rect(0, 0, 3, 2), color.Palette{color.Black, color.White})
+	pal.Pix[4] = 1
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, pal); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return fstest.MapFS{
+		"game/assets.json":      {Data: []byte(testManifest)},
+		"game/img/hero.png":     {Data: testPNG(t, 8, 4)},
+		"game/img/tiles.png":    {Data: testPNG(t, 16, 16)},
+		"game/img/map.png":      {Data: buf.Bytes()},
+		"game/levels/town.json": {Data: []byte(testLevel)},
+	}
+}
+
+func TestLoadAssets(t *testing.T) {
+	defer func() {
+		allData, sizes, imageRefs = make(map[string][]byte), make(map[string]vec.I2), make(map[string]int)
+	}()
+
+	a, err := LoadAssets(testAssetFS(t), "game/assets.json")
+	if err != nil {
+		t.Fatalf("LoadAssets: %v", err)
+	}
+	for _, k := range []string{"hero", "tiles", "map"} {
+		if _, ok := allData[k]; !ok {
+			t.Errorf("image %q not registered", k)
+		}
+	}
+	hero := a.Sheets["hero"]
+	if hero == nil || len(hero.FrameInfos) != 2 || hero.FrameInfos[1].Offset != (vec.I2{2, 3}) || hero.FrameInfos[1].Next != 0 {
+		t.Errorf("hero sheet = %+v, want 2 frames with offset (2, 3)", hero)
+	}
+	if tree := a.Sheets["tree"]; tree == nil || tree.FrameInfos[0].Duration != -1 {
+		t.Errorf("tree sheet = %+v, want 1 frame of duration -1", tree)
+	}
+	l, err := a.LevelNamed("town")
+	if err != nil {
+		t.Fatalf("LevelNamed(town): %v", err)
+	}
+	if got, want := l.MapSize, (vec.I2{3, 2}); got != want {
+		t.Errorf("MapSize = %v, want %v", got, want)
+	}
+	if got, want := l.TileMap[4], uint8(1); got != want {
+		t.Errorf("TileMap[4] = %d, want %d", got, want)
+	}
+	if len(l.TileInfos) != 2 || !l.TileInfos[1].Blocking {
+		t.Errorf("TileInfos = %v, want water blocking", l.TileInfos)
+	}
+	if len(l.Doodads) != 1 || l.Doodads[0].BaseDoodad != a.Doodads["tree"] || l.Doodads[0].P != (vec.I2{12, 20}) {
+		t.Errorf("Doodads = %v, want the tree at (12, 20)", l.Doodads)
+	}
+}
+
+func TestLoadAssetsErrors(t *testing.T) {
+	defer func() {
+		allData, sizes, imageRefs = make(map[string][]byte), make(map[string]vec.I2), make(map[string]int)
+	}()
+
+	tests := []struct {
+		edit func(fstest.MapFS)
+		want string
+	}{
+		{
+			func(fsys fstest.MapFS) { delete(fsys, "game/img/tiles.png") },
+			`game/assets.json: image "tiles": open game/img/tiles.png`,
+		},
+		{
+			func(fsys fstest.MapFS) {
+				fsys["game/assets.json"].Data = []byte(strings.Replace(testManifest, `"image": "hero"`, `"image": "heroo"`, 1))
+			},
+			`game/assets.json: sheet "hero": unknown image "heroo"`,
+		},
+		{
+			func(fsys fstest.MapFS) {
+				fsys["game/levels/town.json"].Data = []byte(strings.Replace(testLevel, `"doodad": "tree"`, `"doodad": "bush"`, 1))
+			},
+			`game/assets.json: level "town": doodads[0]: unknown doodad "bush"`,
+		},
+	}
+	for _, test := range tests {
+		fsys := testAssetFS(t)
+		test.edit(fsys)
+		_, err := LoadAssets(fsys, "game/assets.json")
+		if err == nil || !strings.HasPrefix(err.Error(), test.want) {
+			t.Errorf("LoadAssets error = %v, want prefix %q", err, test.want)
+		}
+	}
+}
+
+func TestAssetsReload(t *testing.T) {
+	defer func() {
+		allData, sizes, imageRefs = make(map[string][]byte), make(map[string]vec.I2), make(map[string]int)
+	}()
+
+	fsys := testAssetFS(t)
+	a, err := LoadAssets(fsys, "game/assets.json")
+	if err != nil {
+		t.Fatalf("LoadAssets: %v", err)
+	}
+	if changed, err := a.Reload(); changed || err != nil {
+		t.Errorf("Reload with nothing changed = %t, %v; want false, nil", changed, err)
+	}
+
+	hero, town := a.Sheets["hero"], a.Levels["town"]
+	now := time.Now()
+	fsys["game/img/hero.png"] = &fstest.MapFile{Data: testPNG(t, 12, 4), ModTime: now}
+	fsys["game/assets.json"] = &fstest.MapFile{Data: []byte(strings.Replace(testManifest, `"frames": 2`, `"frames": 3`, 1)), ModTime: now}
+	if changed, err := a.Reload(); !changed || err != nil {
+		t.Fatalf("Reload after changes = %t, %v; want true, nil", changed, err)
+	}
+	if a.Sheets["hero"] != hero || len(hero.FrameInfos) != 3 {
+		t.Errorf("hero sheet not updated in place: %p != %p or %d frames, want 3", a.Sheets["hero"], hero, len(hero.FrameInfos))
+	}
+	if a.Levels["town"] != town || !a.hasLevel(town) {
+		t.Error("town level not updated in place")
+	}
+	if got, want := sizes["hero"], (vec.I2{12, 4}); got != want {
+		t.Errorf("sizes[hero] = %v, want %v", got, want)
+	}
+	if got, want := imageRefs["hero"], 1; got != want {
+		t.Errorf("imageRefs[hero] = %d, want %d", got, want)
+	}
+
+	// Images dropped from the manifest are unloaded, unless still used.
+	dropped := strings.Replace(testManifest, `"hero": "img/hero.png", `, "", 1)
+	fsys["game/assets.json"] = &fstest.MapFile{Data: []byte(dropped), ModTime: now.Add(time.Second)}
+	if _, err := a.Reload(); err == nil {
+		t.Error("Reload with a sheet using a dropped image: error = nil, want error")
+	}
+	if _, ok := allData["hero"]; !ok {
+		t.Error("hero unloaded although the reload failed")
+	}
+	dropped = strings.Replace(dropped, `"hero": {"image": "hero", "frame_size": [4, 4], "frames": 2, "duration": 3, "offset": [2, 3]},`, "", 1)
+	fsys["game/assets.json"] = &fstest.MapFile{Data: []byte(dropped), ModTime: now.Add(2 * time.Second)}
+	if changed, err := a.Reload(); !changed || err != nil {
+		t.Fatalf("Reload after dropping hero = %t, %v; want true, nil", changed, err)
+	}
+	if _, ok := allData["hero"]; ok {
+		t.Error("hero still loaded after it was dropped from the manifest")
+	}
+	if _, ok := imageRefs["hero"]; ok {
+		t.Errorf("imageRefs[hero] = %d after it was dropped, want none", imageRefs["hero"])
+	}
+	if changed, err := a.Reload(); changed || err != nil {
+		t.Errorf("Reload with nothing changed since = %t, %v; want false, nil", changed, err)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/DrJosh9000/vec"
+)
+
+// atlasPlace is where an image is in the texture atlas.
+type atlasPlace struct {
+	page int
+	pos  vec.I2
+	size vec.I2
+}
+
+// rect is the area the image occupies on its page.
+func (pl atlasPlace) rect() vec.Rect { return vec.Rect{pl.pos, pl.pos.Add(pl.size)} }
+
+// maxRects packs rectangles into one page with the MaxRects algorithm, placing
+// each in the free rectangle that leaves the shortest leftover side.
+type maxRects struct {
+	free []vec.Rect // maximal free rectangles, possibly overlapping
+}
+
+func newMaxRects(size vec.I2) *maxRects {
+	return &maxRects{free: []vec.Rect{{vec.I2{0, 0}, size}}}
+}
+
+// insert finds a place for a rectangle of the given size, or returns false if
+// it doesn't fit.
+func (m *maxRects) insert(sz vec.I2) (vec.I2, bool) {
+	best, bestShort, bestLong := -1, 0, 0
+	for i, f := range m.free {
+		fs := f.Size()
+		if fs.X < sz.X || fs.Y < sz.Y {
+			continue
+		}
+		short, long := fs.X-sz.X, fs.Y-sz.Y
+		if short > long {
+			short, long = long, short
+		}
+		if best < 0 || short < bestShort || (short == bestShort && long < bestLong) {
+			best, bestShort, bestLong = i, short, long
+		}
+	}
+	if best < 0 {
+		return vec.I2{}, false
+	}
+	ul := m.free[best].UL
+	m.place(vec.Rect{ul, ul.Add(sz)})
+	return ul, true
+}
+
+// place splits the free rectangles overlapping used, and prunes any free
+// rectangles contained in others.
+func (m *maxRects) place(used vec.Rect) {
+	var split []vec.Rect
+	for _, f := range m.free {
+		if !rectsOverlap(f, used) {
+			split = append(split, f)
+			continue
+		}
+		if used.UL.X > f.UL.X {
+			split = append(split, vec.Rect{f.UL, vec.I2{used.UL.X, f.DR.Y}})
+		}
+		if used.DR.X < f.DR.X {
+			split = append(split, vec.Rect{vec.I2{used.DR.X, f.UL.Y}, f.DR})
+		}
+		if used.UL.Y > f.UL.Y {
+			split = append(split, vec.Rect{f.UL, vec.I2{f.DR.X, used.UL.Y}})
+		}
+		if used.DR.Y < f.DR.Y {
+			split = append(split, vec.Rect{vec.I2{f.UL.X, used.DR.Y}, f.DR})
+		}
+	}
+	m.prune(split)
+}
+
+// release makes a previously placed rectangle free again. It isn't merged with
+// neighbouring free space, so the page fragments until it is repacked.
+func (m *maxRects) release(r vec.Rect) {
+	m.prune(append(m.free, r))
+}
+
+// prune sets the free rectangles to those of split not contained in others.
+func (m *maxRects) prune(split []vec.Rect) {
+	free := make([]vec.Rect, 0, len(split))
+	for i, a := range split {
+		redundant := false
+		for j, b := range split {
+			// Of two equal rectangles, keep the first.
+			if i != j && rectInside(a, b) && (a != b || j < i) {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			free = append(free, a)
+		}
+	}
+	m.free = free
+}
+
+// rectsOverlap reports whether two rectangles share any area.
+func rectsOverlap(a, b vec.Rect) bool {
+	return a.UL.X < b.DR.X && b.UL.X < a.DR.X && a.UL.Y < b.DR.Y && b.UL.Y < a.DR.Y
+}
+
+// rectInside reports whether a is entirely within b.
+func rectInside(a, b vec.Rect) bool {
+	return a.UL.X >= b.UL.X && a.UL.Y >= b.UL.Y && a.DR.X <= b.DR.X && a.DR.Y <= b.DR.Y
+}
+
+// bySize sorts image keys tallest first, then widest first, then by key.
+type bySize struct {
+	keys  []string
+	sizes map[string]vec.I2
+}
+
+func (s bySize) Len() int      { return len(s.keys) }
+func (s bySize) Swap(i, j int) { s.keys[i], s.keys[j] = s.keys[j], s.keys[i] }
+func (s bySize) Less(i, j int) bool {
+	a, b := s.sizes[s.keys[i]], s.sizes[s.keys[j]]
+	if a.Y != b.Y {
+		return a.Y > b.Y
+	}
+	if a.X != b.X {
+		return a.X > b.X
+	}
+	return s.keys[i] < s.keys[j]
+}
+
+// packAtlas places images of the given sizes onto as few pages as it can,
+// opening a new page when an image doesn't fit on any existing one. Larger
+// images are placed first, which packs better.
+func packAtlas(sizes map[string]vec.I2, pageSize vec.I2) (places map[string]atlasPlace, packers []*maxRects, err error) {
+	keys := make([]string, 0, len(sizes))
+	for k, sz := range sizes {
+		if sz.X > pageSize.X || sz.Y > pageSize.Y {
+			return nil, nil, fmt.Errorf("image %q (%dx%d) is larger than an atlas page (%dx%d)", k, sz.X, sz.Y, pageSize.X, pageSize.Y)
+		}
+		keys = append(keys, k)
+	}
+	sort.Sort(bySize{keys, sizes})
+
+	places = make(map[string]atlasPlace, len(keys))
+keys:
+	for _, k := range keys {
+		for i, m := range packers {
+			if p, ok := m.insert(sizes[k]); ok {
+				places[k] = atlasPlace{i, p, sizes[k]}
+				continue keys
+			}
+		}
+		m := newMaxRects(pageSize)
+		packers = append(packers, m)
+		p, _ := m.insert(sizes[k])
+		places[k] = atlasPlace{len(packers) - 1, p, sizes[k]}
+	}
+	return places, packers, nil
+}
+
+// atlasPageFile is the file name for dumping a page of the atlas: name itself
+// for the first page, then with -1, -2, ... before the extension.
+func atlasPageFile(name string, page int) string {
+	if page == 0 {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), page, ext)
+}
+
+// dumpAtlas writes each page as a PNG.
+func dumpAtlas(name string, pages []*image.NRGBA) error {
+	for i, p := range pages {
+		f, err := os.Create(atlasPageFile(name, i))
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(f, p); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/DrJosh9000/vec"
+)
+
+func TestPackAtlas(t *testing.T) {
+	page := vec.I2{64, 64}
+	sizes := make(map[string]vec.I2)
+	area := 0
+	for i := 0; i < 40; i++ {
+		sz := vec.I2{4 + i*7%20, 3 + i*11%17}
+		sizes[fmt.Sprintf("img%d", i)] = sz
+		area += sz.X * sz.Y
+	}
+	places, packers, err := packAtlas(sizes, page)
+	if err != nil {
+		t.Fatalf("packAtlas: %v", err)
+	}
+	pages := len(packers)
+	if min := (area + page.X*page.Y - 1) / (page.X * page.Y); pages < min || pages > min+1 {
+		t.Errorf("packAtlas used %d pages for %d pixels, want %d or %d", pages, area, min, min+1)
+	}
+	rects := make(map[string]vec.Rect)
+	for k, sz := range sizes {
+		pl, ok := places[k]
+		if !ok {
+			t.Fatalf("image %q wasn't placed", k)
+		}
+		r := vec.Rect{pl.pos, pl.pos.Add(sz)}
+		if !rectInside(r, vec.Rect{vec.I2{0, 0}, page}) || pl.page < 0 || pl.page >= pages {
+			t.Errorf("image %q placed off the page: page %d, %v", k, pl.page, r)
+		}
+		for k2, r2 := range rects {
+			if places[k2].page == pl.page && rectsOverlap(r, r2) {
+				t.Errorf("images %q %v and %q %v overlap", k, r, k2, r2)
+			}
+		}
+		rects[k] = r
+	}
+}
+
+func TestPackAtlasTooBig(t *testing.T) {
+	_, _, err := packAtlas(map[string]vec.I2{"huge": {65, 10}}, vec.I2{64, 64})
+	if err == nil || !strings.Contains(err.Error(), `"huge"`) {
+		t.Errorf("packAtlas(huge image) error = %v, want error naming the image", err)
+	}
+}
+
+func TestAtlasPageFile(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		page int
+		want string
+	}{
+		{"atlas.png", 0, "atlas.png"},
+		{"atlas.png", 2, "atlas-2.png"},
+		{"/tmp/atlas", 1, "/tmp/atlas-1"},
+	} {
+		if got := atlasPageFile(test.name, test.page); got != test.want {
+			t.Errorf("atlasPageFile(%q, %d) = %q, want %q", test.name, test.page, got, test.want)
+		}
+	}
+}
+
+// testPNG encodes a blank image of the given size.
+func testPNG(t *testing.T, w, h int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestRuntimeImages(t *testing.T) {
+	defer func(c *Config, ps vec.I2) { config, atlasPageSize = c, ps }(config, atlasPageSize)
+	config = &Config{}
+	atlasPageSize = vec.I2{8, 8}
+	reset := func() {
+		allData, sizes, imageRefs = make(map[string][]byte), make(map[string]vec.I2), make(map[string]int)
+		atlasPending, atlasPlaces, atlasPackers = make(map[string]bool), make(map[string]atlasPlace), nil
+		atlasLoaded, atlasFreed = false, 0
+	}
+	reset()
+	defer reset()
+
+	// Pack the atlas as loadAllImages would, without drawing it.
+	RegisterImage("a", testPNG(t, 4, 8))
+	RegisterImage("b", testPNG(t, 4, 8))
+	places, packers, err := packAtlas(sizes, atlasPageSize)
+	if err != nil {
+		t.Fatalf("packAtlas: %v", err)
+	}
+	atlasPlaces, atlasPackers, atlasLoaded = places, packers, true
+
+	// The first page is full, so c goes on a new page.
+	RegisterImage("c", testPNG(t, 8, 8))
+	if got, want := sizes["c"], (vec.I2{8, 8}); got != want {
+		t.Errorf("sizes[c] = %v, want %v", got, want)
+	}
+	if !atlasPending["c"] {
+		t.Error("c registered at runtime isn't pending")
+	}
+	if !placeImage("c", sizes["c"]) {
+		t.Fatal("placeImage(c) = false, want true")
+	}
+	if pl := atlasPlaces["c"]; pl.page != 1 || len(atlasPackers) != 2 {
+		t.Errorf("c placed on page %d with %d pages, want page 1 of 2", pl.page, len(atlasPackers))
+	}
+
+	// a is still referenced after one UnloadImage.
+	RegisterImage("a", allData["a"])
+	UnloadImage("a")
+	if _, ok := atlasPlaces["a"]; !ok {
+		t.Error("a unloaded while still referenced")
+	}
+	UnloadImage("a")
+	if _, ok := atlasPlaces["a"]; ok {
+		t.Error("a still in the atlas after releasing all references")
+	}
+	if got, want := atlasFreed, 32; got != want {
+		t.Errorf("atlasFreed = %d, want %d", got, want)
+	}
+
+	// d reuses a's space.
+	RegisterImage("d", testPNG(t, 4, 8))
+	if !placeImage("d", sizes["d"]) {
+		t.Fatal("placeImage(d) = false, want true")
+	}
+	if pl := atlasPlaces["d"]; pl.page != 0 || len(atlasPackers) != 2 {
+		t.Errorf("d placed on page %d with %d pages, want page 0 of 2", pl.page, len(atlasPackers))
+	}
+
+	// b grows; it doesn't fit, but defragmenting might make room.
+	RegisterImage("b", testPNG(t, 8, 8))
+	oldB := atlasPlaces["b"]
+	if placeImage("b", sizes["b"]) {
+		t.Errorf("placeImage(b) = true with space freed, want false")
+	}
+	// It stays where it was until the atlas is defragmented.
+	if got := atlasPlaces["b"]; got != oldB {
+		t.Errorf("after failing to place b, it is at %v, want %v", got, oldB)
+	}
+	if _, ok := atlasPackers[0].insert(vec.I2{4, 8}); ok {
+		t.Error("b's old space was given up")
+	}
+
+	// An image that fails to decode stays pending, and so do the others.
+	atlasPending = map[string]bool{"bad": true, "d": true}
+	allData["bad"], sizes["bad"] = []byte("not a PNG"), vec.I2{8, 8} // sorted first
+	if err := updateAtlas(); err == nil {
+		t.Error("updateAtlas with bad image data: error = nil, want error")
+	}
+	if !atlasPending["bad"] || !atlasPending["d"] {
+		t.Errorf("after a decoding error, pending = %v, want bad and d", atlasPending)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import "log"
+
+// Audio plays sounds identified by keys. The engine uses it for dialogue voice
+// clips and text blips; how keys map to sound data is up to the implementation.
+type Audio interface {
+	// Play starts playing the sound.
+	Play(key string) error
+
+	// Stop stops the sound, if it is playing.
+	Stop(key string)
+
+	// Duration is the length of the sound in model frames, or 0 if unknown.
+	Duration(key string) int
+}
+
+// AudioGame can optionally be implemented by a Game to play sounds. Games
+// that don't are silent.
+type AudioGame interface {
+	Audio() Audio
+}
+
+// audio is the game's Audio, or NullAudio.
+var audio Audio = NullAudio{}
+
+// NullAudio is an Audio that plays nothing. It is used for games without
+// sound, and suits headless tests.
+type NullAudio struct{}
+
+func (NullAudio) Play(string) error   { return nil }
+func (NullAudio) Stop(string)         {}
+func (NullAudio) Duration(string) int { return 0 }
+
+// playSound plays a sound, logging any error in debug mode.
+func playSound(key string) {
+	if key == "" {
+		return
+	}
+	if err := audio.Play(key); err != nil && config.Debug {
+		log.Printf("playing sound %q: %v", key, err)
+	}
+}
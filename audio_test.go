@@ -0,0 +1,52 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// testAudio is an Audio that records the sounds played, and reports
+// Durations (if set).
+type testAudio struct {
+	Durations map[string]int
+	Played    []string
+	Err       error // returned by Play
+}
+
+func (a *testAudio) Play(key string) error {
+	a.Played = append(a.Played, key)
+	return a.Err
+}
+
+func (a *testAudio) Stop(key string) {}
+
+func (a *testAudio) Duration(key string) int { return a.Durations[key] }
+
+func TestPlaySound(t *testing.T) {
+	defer func(a Audio, c *Config) { audio, config = a, c }(audio, config)
+	ta := new(testAudio)
+	audio, config = ta, &Config{Debug: true}
+
+	playSound("door")
+	playSound("") // no sound
+	ta.Err = errors.New("no such sound")
+	playSound("missing") // logged, not fatal
+	if got, want := ta.Played, []string{"door", "missing"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Played = %q, want %q", got, want)
+	}
+}
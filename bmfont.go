@@ -0,0 +1,333 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// BMFont is a Font loaded from an AngelCode BMFont descriptor, in any of the
+// text, XML, or binary formats.
+type BMFont struct {
+	key        string
+	lineHeight int
+	metrics    CharMetrics
+	pages      int
+	kerning    map[[2]rune]int
+}
+
+// LoadBMFont parses a BMFont descriptor and registers its page images (and
+// inverted copies, with RGB inverted and alpha preserved) with RegisterImage.
+// The page images are read with the page func, given each file name in the
+// descriptor. Images are registered with keys derived from key.
+func LoadBMFont(key string, desc []byte, page func(file string) ([]byte, error)) (*BMFont, error) {
+	d, err := parseBMFont(desc)
+	if err != nil {
+		return nil, fmt.Errorf("parsing BMFont %q: %v", key, err)
+	}
+	f := &BMFont{
+		key:        key,
+		lineHeight: d.Common.LineHeight,
+		metrics:    make(CharMetrics, len(d.Chars)),
+		pages:      len(d.Pages),
+		kerning:    make(map[[2]rune]int, len(d.Kernings)),
+	}
+	for i, p := range d.Pages {
+		if p.ID != i {
+			return nil, fmt.Errorf("BMFont %q: page %d has id %d", key, i, p.ID)
+		}
+		img, err := page(p.File)
+		if err != nil {
+			return nil, fmt.Errorf("BMFont %q: reading page %q: %v", key, p.File, err)
+		}
+		inv, err := invertPNG(img)
+		if err != nil {
+			return nil, fmt.Errorf("BMFont %q: inverting page %q: %v", key, p.File, err)
+		}
+		RegisterImage(f.PageKey(i, false), img)
+		RegisterImage(f.PageKey(i, true), inv)
+	}
+	for _, c := range d.Chars {
+		if c.Page < 0 || c.Page >= len(d.Pages) {
+			return nil, fmt.Errorf("BMFont %q: char %d is on unknown page %d", key, c.ID, c.Page)
+		}
+		f.metrics[rune(c.ID)] = CharInfo{
+			X:        c.X,
+			Y:        c.Y,
+			Width:    c.Width,
+			Height:   c.Height,
+			XOffset:  c.XOffset,
+			YOffset:  c.YOffset,
+			XAdvance: c.XAdvance,
+			Page:     c.Page,
+		}
+	}
+	for _, k := range d.Kernings {
+		f.kerning[[2]rune{rune(k.First), rune(k.Second)}] = k.Amount
+	}
+	return f, nil
+}
+
+func (f *BMFont) ImageKey(invert bool) string { return f.PageKey(0, invert) }
+func (f *BMFont) Metrics() CharMetrics        { return f.metrics }
+func (f *BMFont) LineHeight() int             { return f.lineHeight }
+func (f *BMFont) YOffset() int                { return 0 }
+func (f *BMFont) Kern(a, b rune) int          { return f.kerning[[2]rune{a, b}] }
+
+// PageKey returns the image key for a page of the font.
+func (f *BMFont) PageKey(page int, invert bool) string {
+	if invert {
+		return fmt.Sprintf("%s/page%d-inverted", f.key, page)
+	}
+	return fmt.Sprintf("%s/page%d", f.key, page)
+}
+
+// invertPNG decodes a PNG, inverts the colours (but not alpha), and encodes it again.
+func invertPNG(data []byte) ([]byte, error) {
+	src, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	b := src.Bounds()
+	dst := image.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(src.At(x, y)).(color.NRGBA)
+			c.R, c.G, c.B = 0xff-c.R, 0xff-c.G, 0xff-c.B
+			dst.SetNRGBA(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// bmfontDesc is the parsed descriptor. The struct tags are for the XML format.
+type bmfontDesc struct {
+	Common struct {
+		LineHeight int `xml:"lineHeight,attr"`
+		Base       int `xml:"base,attr"`
+	} `xml:"common"`
+	Pages    []bmPage    `xml:"pages>page"`
+	Chars    []bmChar    `xml:"chars>char"`
+	Kernings []bmKerning `xml:"kernings>kerning"`
+}
+
+type bmPage struct {
+	ID   int    `xml:"id,attr"`
+	File string `xml:"file,attr"`
+}
+
+type bmChar struct {
+	ID       int `xml:"id,attr"`
+	X        int `xml:"x,attr"`
+	Y        int `xml:"y,attr"`
+	Width    int `xml:"width,attr"`
+	Height   int `xml:"height,attr"`
+	XOffset  int `xml:"xoffset,attr"`
+	YOffset  int `xml:"yoffset,attr"`
+	XAdvance int `xml:"xadvance,attr"`
+	Page     int `xml:"page,attr"`
+}
+
+type bmKerning struct {
+	First  int `xml:"first,attr"`
+	Second int `xml:"second,attr"`
+	Amount int `xml:"amount,attr"`
+}
+
+func parseBMFont(desc []byte) (*bmfontDesc, error) {
+	d := new(bmfontDesc)
+	var err error
+	switch {
+	case bytes.HasPrefix(desc, []byte("BMF")):
+		err = d.parseBinary(desc)
+	case bytes.HasPrefix(bytes.TrimSpace(desc), []byte("<")):
+		err = xml.Unmarshal(desc, d)
+	default:
+		err = d.parseText(desc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(d.Pages) == 0 {
+		return nil, errors.New("no pages")
+	}
+	return d, nil
+}
+
+// parseText parses the text format: lines of a tag followed by key=value pairs.
+func (d *bmfontDesc) parseText(desc []byte) error {
+	sc := bufio.NewScanner(bytes.NewReader(desc))
+	for n := 1; sc.Scan(); n++ {
+		tag, attrs, err := splitBMFontLine(sc.Text())
+		if err != nil {
+			return fmt.Errorf("line %d: %v", n, err)
+		}
+		num := func(k string) int {
+			v, ok := attrs[k]
+			if !ok || err != nil {
+				return 0
+			}
+			i, e := strconv.Atoi(v)
+			if e != nil {
+				err = fmt.Errorf("attribute %s: %v", k, e)
+			}
+			return i
+		}
+		switch tag {
+		case "common":
+			d.Common.LineHeight = num("lineHeight")
+			d.Common.Base = num("base")
+		case "page":
+			d.Pages = append(d.Pages, bmPage{ID: num("id"), File: attrs["file"]})
+		case "char":
+			d.Chars = append(d.Chars, bmChar{
+				ID:       num("id"),
+				X:        num("x"),
+				Y:        num("y"),
+				Width:    num("width"),
+				Height:   num("height"),
+				XOffset:  num("xoffset"),
+				YOffset:  num("yoffset"),
+				XAdvance: num("xadvance"),
+				Page:     num("page"),
+			})
+		case "kerning":
+			d.Kernings = append(d.Kernings, bmKerning{
+				First:  num("first"),
+				Second: num("second"),
+				Amount: num("amount"),
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %v", n, err)
+		}
+	}
+	return sc.Err()
+}
+
+// splitBMFontLine splits a line of the text format into the tag and attributes.
+// Values may be quoted.
+func splitBMFontLine(line string) (tag string, attrs map[string]string, err error) {
+	line = strings.TrimSpace(line)
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		tag, line = line[:i], line[i:]
+	} else {
+		return line, nil, nil
+	}
+	attrs = make(map[string]string)
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			return tag, attrs, nil
+		}
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return "", nil, fmt.Errorf("expected key=value, got %q", line)
+		}
+		k := line[:eq]
+		line = line[eq+1:]
+		var v string
+		if strings.HasPrefix(line, `"`) {
+			end := strings.Index(line[1:], `"`)
+			if end < 0 {
+				return "", nil, fmt.Errorf("unterminated quote in value of %s", k)
+			}
+			v, line = line[1:end+1], line[end+2:]
+		} else {
+			end := strings.IndexAny(line, " \t")
+			if end < 0 {
+				end = len(line)
+			}
+			v, line = line[:end], line[end:]
+		}
+		attrs[k] = v
+	}
+}
+
+// parseBinary parses the binary format (version 3).
+func (d *bmfontDesc) parseBinary(desc []byte) error {
+	if len(desc) < 4 || desc[3] != 3 {
+		return errors.New("unsupported binary BMFont version")
+	}
+	le := binary.LittleEndian
+	b := desc[4:]
+	for len(b) > 0 {
+		if len(b) < 5 {
+			return errors.New("truncated block header")
+		}
+		typ, size := b[0], int(le.Uint32(b[1:5]))
+		b = b[5:]
+		if size > len(b) {
+			return fmt.Errorf("block %d: size %d exceeds remaining %d bytes", typ, size, len(b))
+		}
+		blk := b[:size]
+		b = b[size:]
+		switch typ {
+		case 2: // common
+			if len(blk) < 4 {
+				return errors.New("truncated common block")
+			}
+			d.Common.LineHeight = int(le.Uint16(blk[0:]))
+			d.Common.Base = int(le.Uint16(blk[2:]))
+		case 3: // pages: null-terminated file names
+			for i, name := range strings.Split(strings.TrimSuffix(string(blk), "\x00"), "\x00") {
+				d.Pages = append(d.Pages, bmPage{ID: i, File: name})
+			}
+		case 4: // chars: 20 bytes each
+			if len(blk)%20 != 0 {
+				return fmt.Errorf("chars block size %d not a multiple of 20", len(blk))
+			}
+			for ; len(blk) > 0; blk = blk[20:] {
+				d.Chars = append(d.Chars, bmChar{
+					ID:       int(le.Uint32(blk[0:])),
+					X:        int(le.Uint16(blk[4:])),
+					Y:        int(le.Uint16(blk[6:])),
+					Width:    int(le.Uint16(blk[8:])),
+					Height:   int(le.Uint16(blk[10:])),
+					XOffset:  int(int16(le.Uint16(blk[12:]))),
+					YOffset:  int(int16(le.Uint16(blk[14:]))),
+					XAdvance: int(int16(le.Uint16(blk[16:]))),
+					Page:     int(blk[18]),
+				})
+			}
+		case 5: // kerning pairs: 10 bytes each
+			if len(blk)%10 != 0 {
+				return fmt.Errorf("kerning block size %d not a multiple of 10", len(blk))
+			}
+			for ; len(blk) > 0; blk = blk[10:] {
+				d.Kernings = append(d.Kernings, bmKerning{
+					First:  int(le.Uint32(blk[0:])),
+					Second: int(le.Uint32(blk[4:])),
+					Amount: int(int16(le.Uint16(blk[8:]))),
+				})
+			}
+		}
+	}
+	return nil
+}
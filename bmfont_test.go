@@ -0,0 +1,137 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+const bmfontText = `info face="Test Font" size=8 bold=0
+common lineHeight=10 base=8 scaleW=64 scaleH=64 pages=1 packed=0
+page id=0 file="test_0.png"
+chars count=2
+char id=65   x=1  y=2  width=5 height=7 xoffset=0 yoffset=1 xadvance=6 page=0 chnl=15
+char id=233  x=7  y=2  width=5 height=9 xoffset=0 yoffset=-1 xadvance=6 page=0 chnl=15
+kernings count=1
+kerning first=65 second=233 amount=-1
+`
+
+const bmfontXML = `<?xml version="1.0"?>
+<font>
+  <info face="Test Font" size="8"/>
+  <common lineHeight="10" base="8" scaleW="64" scaleH="64" pages="1" packed="0"/>
+  <pages>
+    <page id="0" file="test_0.png"/>
+  </pages>
+  <chars count="2">
+    <char id="65" x="1" y="2" width="5" height="7" xoffset="0" yoffset="1" xadvance="6" page="0" chnl="15"/>
+    <char id="233" x="7" y="2" width="5" height="9" xoffset="0" yoffset="-1" xadvance="6" page="0" chnl="15"/>
+  </chars>
+  <kernings count="1">
+    <kerning first="65" second="233" amount="-1"/>
+  </kernings>
+</font>`
+
+func bmfontBinary() []byte {
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	block := func(typ byte, data ...interface{}) {
+		var b bytes.Buffer
+		for _, d := range data {
+			binary.Write(&b, le, d)
+		}
+		buf.WriteByte(typ)
+		binary.Write(&buf, le, uint32(b.Len()))
+		buf.Write(b.Bytes())
+	}
+	buf.WriteString("BMF\x03")
+	block(1, int16(8), uint8(0), uint8(0), uint16(100), uint8(1), uint8(1), uint8(0), uint8(0), uint8(0), uint8(0), uint8(0), uint8(0), []byte("Test Font\x00"))
+	block(2, uint16(10), uint16(8), uint16(64), uint16(64), uint16(1), uint8(0), uint8(0), uint8(0), uint8(0), uint8(0))
+	block(3, []byte("test_0.png\x00"))
+	block(4,
+		uint32(65), uint16(1), uint16(2), uint16(5), uint16(7), int16(0), int16(1), int16(6), uint8(0), uint8(15),
+		uint32(233), uint16(7), uint16(2), uint16(5), uint16(9), int16(0), int16(-1), int16(6), uint8(0), uint8(15),
+	)
+	block(5, uint32(65), uint32(233), int16(-1))
+	return buf.Bytes()
+}
+
+func TestLoadBMFont(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	img.SetNRGBA(1, 0, color.NRGBA{0x10, 0x20, 0x30, 0x40})
+	var pngBuf bytes.Buffer
+	if err := png.Encode(&pngBuf, img); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	page := func(file string) ([]byte, error) {
+		if file != "test_0.png" {
+			t.Errorf("Got page file %q, want test_0.png", file)
+		}
+		return pngBuf.Bytes(), nil
+	}
+	wantMetrics := CharMetrics{
+		'A': {X: 1, Y: 2, Width: 5, Height: 7, XOffset: 0, YOffset: 1, XAdvance: 6},
+		'é': {X: 7, Y: 2, Width: 5, Height: 9, XOffset: 0, YOffset: -1, XAdvance: 6},
+	}
+
+	for name, desc := range map[string][]byte{
+		"text":   []byte(bmfontText),
+		"xml":    []byte(bmfontXML),
+		"binary": bmfontBinary(),
+	} {
+		f, err := LoadBMFont("test-"+name, desc, page)
+		if err != nil {
+			t.Errorf("LoadBMFont(%s): %v", name, err)
+			continue
+		}
+		if got := f.Metrics(); !reflect.DeepEqual(got, wantMetrics) {
+			t.Errorf("%s: got metrics %v, want %v", name, got, wantMetrics)
+		}
+		if got, want := f.LineHeight(), 10; got != want {
+			t.Errorf("%s: got LineHeight %d, want %d", name, got, want)
+		}
+		if got, want := f.Kern('A', 'é'), -1; got != want {
+			t.Errorf("%s: got Kern(A, é) %d, want %d", name, got, want)
+		}
+		if got, want := f.Kern('é', 'A'), 0; got != want {
+			t.Errorf("%s: got Kern(é, A) %d, want %d", name, got, want)
+		}
+		if _, ok := allData[f.ImageKey(false)]; !ok {
+			t.Errorf("%s: image %q not registered", name, f.ImageKey(false))
+		}
+		inv, ok := allData[f.ImageKey(true)]
+		if !ok {
+			t.Errorf("%s: inverted image %q not registered", name, f.ImageKey(true))
+			continue
+		}
+		i, err := png.Decode(bytes.NewReader(inv))
+		if err != nil {
+			t.Errorf("%s: decoding inverted image: %v", name, err)
+			continue
+		}
+		for x, want := range []color.NRGBA{{0, 0, 0, 0xff}, {0xef, 0xdf, 0xcf, 0x40}} {
+			if got := color.NRGBAModel.Convert(i.At(x, 0)); got != want {
+				t.Errorf("%s: inverted pixel %d = %v, want %v", name, x, got, want)
+			}
+		}
+	}
+}
@@ -23,27 +23,36 @@ import (
 type Button struct {
 	*Bubble
 	*Text
-	Action func()
-	added  bool
+	Action  func()
+	Focused bool // drawn pressed, for keyboard or gamepad navigation
+	added   bool
 }
 
 func NewButton(text string, action func(), bounds vec.Rect, parent *View) *Button {
+	return newButton(&Text{Text: text}, action, bounds, parent)
+}
+
+// NewMsgButton creates a button with a localized label.
+func NewMsgButton(msg *Msg, action func(), bounds vec.Rect, parent *View) *Button {
+	return newButton(&Text{Msg: msg}, action, bounds, parent)
+}
+
+func newButton(text *Text, action func(), bounds vec.Rect, parent *View) *Button {
 	if config.Debug {
-		log.Printf("NewButton: text %q, bounds %v", text, bounds)
+		log.Printf("NewButton: text %q, msg %v, bounds %v", text.Text, text.Msg, bounds)
 	}
 	sz := bounds.Size()
 	bk, _ := game.BubbleKey()
+	text.View = &View{}
+	text.Font = game.Font()
+	text.BoldFont = gameBoldFont()
 	b := &Button{
 		Action: action,
 		Bubble: &Bubble{
 			View: &View{},
 			Key:  bk,
 		},
-		Text: &Text{
-			View: &View{},
-			Text: text,
-			Font: game.Font(),
-		},
+		Text: text,
 	}
 	b.Bubble.View.SetParent(parent)
 	b.Bubble.View.SetBounds(bounds)
@@ -54,11 +63,17 @@ func NewButton(text string, action func(), bounds vec.Rect, parent *View) *Butto
 	sz = sz.Sub(bubblePartSize.Mul(2))
 	b.Text.View.SetSize(sz)
 	b.Text.Layout(true)
-	// Text should now have the minimal size. Centre the text within button, but offset slightly.
-	b.Text.View.SetPosition(bounds.Size().Sub(b.Text.View.Size()).Div(2).Sub(vec.I2{1, 1}))
+	b.centreText()
+	b.Text.relaid = b.centreText
 	return b
 }
 
+// centreText positions the (laid out) text within the button.
+func (b *Button) centreText() {
+	// Text should now have the minimal size. Centre the text within button, but offset slightly.
+	b.Text.View.SetPosition(b.Bubble.View.Size().Sub(b.Text.View.Size()).Div(2).Sub(vec.I2{1, 1}))
+}
+
 func (b *Button) Dispose() {
 	b.Bubble.Dispose()
 	b.Bubble = nil
@@ -82,8 +97,11 @@ func (b *Button) Handle(e *Event) (handled bool) {
 		}
 		return false
 	}
-	b.Text.Invert = false
+	b.Text.Invert = b.Focused
 	b.Bubble.Key = k1
+	if b.Focused {
+		b.Bubble.Key = k2
+	}
 	return false
 }
 
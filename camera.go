@@ -0,0 +1,225 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/DrJosh9000/vec"
+)
+
+// Camera positions the scene's World view. It can follow a sprite with easing
+// and a dead zone, pan to places for cutscenes, and shake. It keeps the view
+// within Bounds, and can magnify the World (but not the HUD) by an integer Zoom.
+//
+// The camera only moves the World while it has something to do: a sprite to
+// follow, a pan, or a shake. Otherwise the World stays wherever it was put.
+type Camera struct {
+	Follow   *Sprite  // sprite to keep in view, if any
+	Ease     float64  // fraction of the remaining distance to move each model frame; 0 snaps
+	DeadZone vec.I2   // size of the box around the centre of the view the followed sprite can move in freely
+	Bounds   vec.Rect // world area to keep the view within; the whole World if empty
+	Zoom     int      // magnification of the World; 0 means 1
+
+	scene  *Scene
+	focus  vec.F2 // world position at the centre of the view, before shaking
+	pan    *cameraPan
+	held   bool // stay at the end of the last pan until Release
+	shake  cameraShake
+	jitter vec.I2 // current shake offset
+}
+
+// cameraPan moves the camera from one place to another over a number of frames.
+type cameraPan struct {
+	from, to      vec.F2
+	frame, frames int
+	done          func()
+}
+
+// cameraShake jiggles the camera by up to magnitude pixels, decreasing over
+// the frames it lasts.
+type cameraShake struct {
+	magnitude, frames, left int
+}
+
+func newCamera(s *Scene) *Camera {
+	c := &Camera{scene: s}
+	c.focus = c.ViewSize().F2().Div(2)
+	return c
+}
+
+func (c *Camera) zoom() int {
+	if c.Zoom < 1 {
+		return 1
+	}
+	return c.Zoom
+}
+
+// ViewSize is the size of the area of the World in view.
+func (c *Camera) ViewSize() vec.I2 { return c.scene.View.Size().Div(c.zoom()) }
+
+// ViewRect is the area of the World in view.
+func (c *Camera) ViewRect() vec.Rect {
+	ul := c.scene.World.RelativeBounds().UL.Mul(-1)
+	return vec.Rect{UL: ul, DR: ul.Add(c.ViewSize())}
+}
+
+// Focus is the world position at the centre of the view, ignoring any shake.
+func (c *Camera) Focus() vec.I2 { return c.focus.I2() }
+
+// ScreenToWorld converts a position on the screen to the World.
+func (c *Camera) ScreenToWorld(p vec.I2) vec.I2 {
+	return p.Div(c.zoom()).Sub(c.scene.World.Position())
+}
+
+// toScreen converts a rectangle in World-view coordinates (such as a world
+// part's Dst) to the screen, which differ when zoomed.
+func (c *Camera) toScreen(r vec.Rect) vec.Rect {
+	z := c.zoom()
+	return vec.Rect{UL: r.UL.Mul(z), DR: r.DR.Mul(z)}
+}
+
+// LookAt moves the camera straight to p, cancelling any pan. A followed sprite
+// draws the camera back to it.
+func (c *Camera) LookAt(p vec.I2) {
+	c.pan, c.held = nil, false
+	c.focus = c.clamp(p.F2())
+	c.apply()
+}
+
+// Snap moves the camera straight to the followed sprite, such as after
+// changing level.
+func (c *Camera) Snap() {
+	if c.Follow == nil {
+		return
+	}
+	c.LookAt(c.Follow.Pos.I2())
+}
+
+// Pan moves the camera to p over a number of model frames, easing in and out,
+// then calls done (if not nil). The camera stays there, ignoring the followed
+// sprite, until Release or LookAt.
+func (c *Camera) Pan(p vec.I2, frames int, done func()) {
+	c.pan = &cameraPan{
+		from:   c.focus,
+		to:     c.clamp(p.F2()),
+		frames: frames,
+		done:   done,
+	}
+	c.held = true
+}
+
+// Panning reports whether the camera is panning, or holding after a pan.
+func (c *Camera) Panning() bool { return c.held }
+
+// Release ends a pan, so the camera returns to following its sprite (eased as
+// usual). A pan in progress stops where it is without calling done.
+func (c *Camera) Release() { c.pan, c.held = nil, false }
+
+// Shake shakes the camera by up to magnitude pixels, dying away over a number
+// of model frames.
+func (c *Camera) Shake(magnitude, frames int) {
+	c.shake = cameraShake{magnitude: magnitude, frames: frames, left: frames}
+}
+
+// update moves the camera one model frame.
+func (c *Camera) update() {
+	if c.Follow == nil && !c.held && c.shake.left == 0 && c.jitter == (vec.I2{}) {
+		return
+	}
+	switch {
+	case c.pan != nil:
+		p := c.pan
+		p.frame++
+		if p.frame >= p.frames {
+			c.focus, c.pan = p.to, nil
+			if p.done != nil {
+				p.done()
+			}
+			break
+		}
+		t := float64(p.frame) / float64(p.frames)
+		t = t * t * (3 - 2*t)
+		c.focus = p.from.Add(p.to.Sub(p.from).Mul(t))
+	case c.held:
+	case c.Follow != nil:
+		c.follow(c.Follow.Pos)
+	}
+	c.jitter = vec.I2{}
+	if s := &c.shake; s.left > 0 {
+		if m := s.magnitude * s.left / s.frames; m > 0 {
+			c.jitter = vec.I2{rand.Intn(2*m+1) - m, rand.Intn(2*m+1) - m}
+		}
+		s.left--
+	}
+	c.apply()
+}
+
+// follow eases the camera towards keeping target inside the dead zone.
+func (c *Camera) follow(target vec.F2) {
+	want := c.focus
+	dz := c.DeadZone.F2().Div(2)
+	switch {
+	case target.X < want.X-dz.X:
+		want.X = target.X + dz.X
+	case target.X > want.X+dz.X:
+		want.X = target.X - dz.X
+	}
+	switch {
+	case target.Y < want.Y-dz.Y:
+		want.Y = target.Y + dz.Y
+	case target.Y > want.Y+dz.Y:
+		want.Y = target.Y - dz.Y
+	}
+	want = c.clamp(want)
+	d := want.Sub(c.focus)
+	if c.Ease <= 0 || c.Ease >= 1 || d.Dot(d) < 0.25 {
+		c.focus = want
+		return
+	}
+	c.focus = c.focus.Add(d.Mul(c.Ease))
+}
+
+// clamp keeps the view centred on p within the bounds, or centres the view on
+// the bounds if they're smaller than the view.
+func (c *Camera) clamp(p vec.F2) vec.F2 {
+	b := c.Bounds
+	if b.Size().X <= 0 || b.Size().Y <= 0 {
+		b = vec.Rect{DR: c.scene.World.Size()}
+	}
+	h := c.ViewSize().F2().Div(2)
+	p.X = clampAxis(p.X, float64(b.UL.X), float64(b.DR.X), h.X)
+	p.Y = clampAxis(p.Y, float64(b.UL.Y), float64(b.DR.Y), h.Y)
+	return p
+}
+
+// clampAxis keeps x within [lo+h, hi-h], or returns the middle if that's empty.
+func clampAxis(x, lo, hi, h float64) float64 {
+	if hi-lo <= 2*h {
+		return (lo + hi) / 2
+	}
+	return math.Max(lo+h, math.Min(x, hi-h))
+}
+
+// apply positions the World for the camera.
+func (c *Camera) apply() {
+	ul := c.focus.Sub(c.ViewSize().F2().Div(2))
+	p := vec.I2{int(math.Floor(ul.X + 0.5)), int(math.Floor(ul.Y + 0.5))}
+	p = p.Add(c.jitter).Mul(-1)
+	if p != c.scene.World.RelativeBounds().UL {
+		c.scene.World.SetPosition(p)
+	}
+}
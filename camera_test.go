@@ -0,0 +1,138 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"testing"
+
+	"github.com/DrJosh9000/vec"
+)
+
+// testCamera has a 100x80 view of a 400x300 world.
+func testCamera() *Camera {
+	return NewScene(vec.I2{100, 80}, vec.I2{400, 300}).Camera
+}
+
+func TestCameraLookAt(t *testing.T) {
+	c := testCamera()
+	tests := []struct {
+		p    vec.I2
+		want vec.I2 // World position
+	}{
+		{vec.I2{200, 150}, vec.I2{-150, -110}},
+		{vec.I2{0, 0}, vec.I2{0, 0}},
+		{vec.I2{400, 300}, vec.I2{-300, -220}},
+	}
+	for _, test := range tests {
+		c.LookAt(test.p)
+		if got := c.scene.World.Position(); got != test.want {
+			t.Errorf("after LookAt(%v): World.Position() = %v, want %v", test.p, got, test.want)
+		}
+	}
+
+	c.Bounds = vec.NewRect(100, 100, 150, 300) // narrower than the view
+	c.LookAt(vec.I2{0, 0})
+	if got, want := c.Focus(), (vec.I2{125, 140}); got != want {
+		t.Errorf("bounded Focus() = %v, want %v", got, want)
+	}
+}
+
+func TestCameraFollow(t *testing.T) {
+	c := testCamera()
+	s := &Sprite{Pos: vec.F2{200, 150}}
+	c.Follow = s
+	c.DeadZone = vec.I2{20, 10}
+	c.Snap()
+
+	s.Pos = vec.F2{205, 154} // within the dead zone
+	c.update()
+	if got, want := c.Focus(), (vec.I2{200, 150}); got != want {
+		t.Errorf("in dead zone: Focus() = %v, want %v", got, want)
+	}
+
+	s.Pos = vec.F2{230, 140}
+	c.update()
+	if got, want := c.Focus(), (vec.I2{220, 145}); got != want {
+		t.Errorf("out of dead zone: Focus() = %v, want %v", got, want)
+	}
+
+	c.Ease = 0.5
+	s.Pos = vec.F2{250, 145}
+	c.update()
+	if got, want := c.Focus(), (vec.I2{230, 145}); got != want {
+		t.Errorf("eased: Focus() = %v, want %v", got, want)
+	}
+}
+
+func TestCameraPan(t *testing.T) {
+	c := testCamera()
+	s := &Sprite{Pos: vec.F2{100, 100}}
+	c.Follow = s
+	c.Snap()
+
+	done := 0
+	c.Pan(vec.I2{300, 200}, 4, func() { done++ })
+	for i := 0; i < 3; i++ {
+		c.update()
+	}
+	if done != 0 {
+		t.Errorf("done called %d times before the pan finished", done)
+	}
+	if got := c.Focus(); got == (vec.I2{100, 100}) || got == (vec.I2{300, 200}) {
+		t.Errorf("mid-pan Focus() = %v, want somewhere between", got)
+	}
+	c.update()
+	c.update()
+	if got, want := c.Focus(), (vec.I2{300, 200}); got != want {
+		t.Errorf("after pan: Focus() = %v, want %v", got, want)
+	}
+	if done != 1 || !c.Panning() {
+		t.Errorf("after pan: done called %d times, Panning() = %t; want 1, true", done, c.Panning())
+	}
+
+	c.Release()
+	c.update()
+	if got, want := c.Focus(), (vec.I2{100, 100}); got != want {
+		t.Errorf("after Release: Focus() = %v, want %v", got, want)
+	}
+}
+
+func TestCameraShake(t *testing.T) {
+	c := testCamera()
+	c.LookAt(vec.I2{200, 150})
+	c.Shake(3, 5)
+	for i := 0; i < 5; i++ {
+		c.update()
+		if j := c.jitter; j.X < -3 || j.X > 3 || j.Y < -3 || j.Y > 3 {
+			t.Errorf("frame %d: jitter %v exceeds magnitude 3", i, j)
+		}
+	}
+	c.update()
+	if got, want := c.scene.World.Position(), (vec.I2{-150, -110}); got != want {
+		t.Errorf("after shake: World.Position() = %v, want %v", got, want)
+	}
+}
+
+func TestCameraZoom(t *testing.T) {
+	c := testCamera()
+	c.Zoom = 2
+	c.LookAt(vec.I2{200, 150})
+	if got, want := c.ViewRect(), vec.NewRect(175, 130, 225, 170); got != want {
+		t.Errorf("ViewRect() = %v, want %v", got, want)
+	}
+	if got, want := c.ScreenToWorld(vec.I2{50, 40}), (vec.I2{200, 150}); got != want {
+		t.Errorf("ScreenToWorld(50, 40) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command awakenmsgs lists message IDs used in Go source that are missing from
+// message catalogs.
+//
+// Usage:
+//
+//	awakenmsgs [-src dir] catalog.json...
+//
+// Message IDs are found in the ID field of Msg composite literals, e.g.
+// &awakengine.Msg{ID: "greeting"}. Each catalog is a JSON file as read by
+// awakengine.LoadCatalog. For each catalog, missing IDs are printed along with
+// where they were used.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var srcDir = flag.String("src", ".", "directory tree of Go source to scan for message IDs")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [-src dir] catalog.json...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ids, err := findIDs(*srcDir)
+	if err != nil {
+		log.Fatalf("Couldn't scan source: %v", err)
+	}
+	missing := false
+	for _, path := range flag.Args() {
+		cat, err := readCatalog(path)
+		if err != nil {
+			log.Fatalf("Couldn't read catalog: %v", err)
+		}
+		var out []string
+		for id := range ids {
+			if _, ok := cat[id]; !ok {
+				out = append(out, id)
+			}
+		}
+		sort.Strings(out)
+		for _, id := range out {
+			fmt.Printf("%s: missing %q (used at %s)\n", path, id, strings.Join(ids[id], ", "))
+			missing = true
+		}
+	}
+	if missing {
+		os.Exit(1)
+	}
+}
+
+// findIDs finds message IDs in all Go files under dir, returning the positions
+// each ID is used at.
+func findIDs(dir string) (map[string][]string, error) {
+	ids := make(map[string][]string)
+	fset := token.NewFileSet()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			cl, ok := n.(*ast.CompositeLit)
+			if !ok || !isMsgType(cl.Type) {
+				return true
+			}
+			for _, e := range cl.Elts {
+				kv, ok := e.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				if k, ok := kv.Key.(*ast.Ident); !ok || k.Name != "ID" {
+					continue
+				}
+				lit, ok := kv.Value.(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					continue
+				}
+				id, err := strconv.Unquote(lit.Value)
+				if err != nil {
+					continue
+				}
+				ids[id] = append(ids[id], fset.Position(lit.Pos()).String())
+			}
+			return true
+		})
+		return nil
+	})
+	return ids, err
+}
+
+// isMsgType reports whether the expression is Msg or pkg.Msg.
+func isMsgType(e ast.Expr) bool {
+	switch t := e.(type) {
+	case *ast.Ident:
+		return t.Name == "Msg"
+	case *ast.SelectorExpr:
+		return t.Sel.Name == "Msg"
+	}
+	return false
+}
+
+func readCatalog(path string) (map[string]json.RawMessage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var cat map[string]json.RawMessage
+	if err := json.NewDecoder(f).Decode(&cat); err != nil {
+		return nil, fmt.Errorf("decoding %s: %v", path, err)
+	}
+	return cat, nil
+}
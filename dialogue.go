@@ -21,19 +21,58 @@ import (
 )
 
 type ButtonSpec struct {
-	Label  string
-	Action func()
+	Label    string
+	LabelMsg *Msg // localized label, used instead of Label if set
+	Action   func()
+	Cancel   bool // chosen by InputCancel
+}
+
+// recordedAction returns the button action, preceded by recording the choice
+// in the transcript.
+func (s *ButtonSpec) recordedAction() func() {
+	return func() {
+		label := s.Label
+		if s.LabelMsg != nil {
+			label = s.LabelMsg.String()
+		}
+		record(TranscriptEntry{Text: label, Choice: true})
+		if s.Action != nil {
+			s.Action()
+		}
+	}
 }
 
 // DialogueLine is information for displaying a singe line of dialogue in a display.
 type DialogueLine struct {
 	Avatar   *SheetFrame
+	Speaker  *Sprite // if set, the line is shown in a bubble anchored above the speaker
+	Name     string  // speaker's name, recorded in the transcript
 	Text     string
+	Msg      *Msg   // localized text, used instead of Text if set
+	Voice    string // sound played when the line is shown; AutoNext waits for it to finish
+	Blip     string // sound played as each character is revealed
 	Buttons  []*ButtonSpec
 	AutoNext bool
 	Slowness int
+
+	// Default is the index of the button focused first, and chosen if Timeout
+	// model frames pass after the text is shown without a choice being made.
+	// If Timeout is 0 there is no time limit.
+	Default int
+	Timeout int
+
+	// Done, if set, is called after the line is dismissed.
+	Done func()
 }
 
+// Dialogue layout metrics.
+const (
+	buttonHeight    = 25
+	buttonGap       = 10 // between buttons, and between rows of buttons
+	buttonPadding   = 10 // between the label and the edges of the button
+	dialogueMinSize = 74 // minimum height of the fixed dialogue box
+)
+
 // Dialogue is all the things needed for displaying blocking dialogue text.
 type DialogueDisplay struct {
 	*View
@@ -41,9 +80,14 @@ type DialogueDisplay struct {
 	bubble  *Bubble
 	buttons []*Button
 	text    *Text
+	tail    *bubbleTail
+	camSize vec.I2
 
 	complete bool
 	frame    int // frame number for this dialogue.
+	focus    int // index of the focused button, or -1
+	waited   int // frames since complete, for timed choices
+	voice    int // frames of the voice clip left to play
 
 	line *DialogueLine
 }
@@ -58,24 +102,22 @@ func NewDialogueDisplay(scene *Scene) *DialogueDisplay {
 			View: &View{},
 		},
 		text: &Text{
-			View: &View{},
-			Font: game.Font(),
+			View:     &View{},
+			Font:     game.Font(),
+			BoldFont: gameBoldFont(),
+			Paged:    true,
 		},
 		bubble: &Bubble{
 			View: &View{},
 			Key:  bk,
 		},
+		camSize: scene.View.Size(),
 	}
 
-	camSize := scene.View.Size()
-	size := vec.I2{camSize.X - 20, 74}
-
 	d.SetParent(scene.HUD)
-	d.SetPositionAndSize(vec.I2{10, camSize.Y - 84}, size)
 	d.SetZ(100) // The topmost of the top...
 
 	d.bubble.SetParent(d.View)
-	d.bubble.SetSize(size)
 	d.bubble.SetZ(1)
 
 	d.avatar.SetParent(d.bubble.View)
@@ -84,9 +126,41 @@ func NewDialogueDisplay(scene *Scene) *DialogueDisplay {
 
 	d.text.SetParent(d.bubble.View)
 	d.text.SetZ(1)
+
+	d.tail = newBubbleTail(d.View)
 	return d
 }
 
+// layoutButtons arranges buttons sized to their labels in rows no wider than
+// width. It returns their bounds relative to the top left of the first row,
+// and the size of all the rows.
+func layoutButtons(specs []*ButtonSpec, font Font, width int) (bounds []vec.Rect, size vec.I2) {
+	if len(specs) == 0 {
+		return nil, vec.I2{}
+	}
+	p := vec.I2{}
+	for _, s := range specs {
+		label := s.Label
+		if s.LabelMsg != nil {
+			label = s.LabelMsg.String()
+		}
+		w := MeasureText(font, label, 0).X + 2*(bubblePartSize.X+buttonPadding)
+		if w > width {
+			w = width
+		}
+		if p.X > 0 && p.X+w > width {
+			p = vec.I2{0, p.Y + buttonHeight + buttonGap}
+		}
+		bounds = append(bounds, vec.Rect{p, p.Add(vec.I2{w, buttonHeight})})
+		if p.X+w > size.X {
+			size.X = p.X + w
+		}
+		p.X += w + buttonGap
+	}
+	size.Y = p.Y + buttonHeight
+	return bounds, size
+}
+
 // Layout rearranges views
 func (d *DialogueDisplay) Layout(line *DialogueLine) {
 	// Dispose of any old buttons first.
@@ -94,43 +168,145 @@ func (d *DialogueDisplay) Layout(line *DialogueLine) {
 		b.Dispose()
 	}
 	d.buttons = nil
+	d.stopVoice()
 
 	// Reset things...
 	d.complete = false
 	d.frame = 0
+	d.focus = -1
+	d.waited = 0
 
 	// Refresh properties from the line.
 	d.avatar.SheetFrame = line.Avatar
 	d.frame = 0
 	d.line = line
 	d.text.Text = line.Text
+	d.text.Msg = line.Msg
+	d.text.Page = 0
+	d.text.Blip = line.Blip
 
-	size := d.Size()
 	textPos := vec.I2{10, 10}
-
+	minHeight := 0
 	if line.Avatar != nil {
 		// Provide space for the avatar.
 		textPos.X += line.Avatar.Sheet.FrameSize.X + 5
 		d.avatar.SetSize(line.Avatar.Sheet.FrameSize)
+		minHeight = line.Avatar.Sheet.FrameSize.Y + 2*bubblePartSize.Y
 	}
 	d.avatar.SetVisible(line.Avatar != nil)
 
-	d.text.SetPositionAndSize(textPos, vec.I2{size.X - textPos.X - 15, 0})
+	textW := d.camSize.X - 20 - textPos.X - 15
+	if line.Speaker != nil {
+		textW = speechMaxWidth
+	}
+	btnBounds, btnSize := layoutButtons(line.Buttons, d.text.Font, textW)
+	chrome := textPos.Y + 10
+	if len(line.Buttons) > 0 {
+		chrome += btnSize.Y + buttonGap
+	}
+
+	// Text that doesn't fit in half the screen is split into pages.
+	lh := d.text.Font.LineHeight()
+	d.text.MaxLines = (d.camSize.Y/2 - chrome) / lh
+	if d.text.MaxLines < 1 {
+		d.text.MaxLines = 1
+	}
+	d.text.SetPositionAndSize(textPos, vec.I2{textW, 0})
 	d.text.Layout(line.Slowness < 0)
 
-	p := vec.I2{textPos.X, size.Y - 40}
-	for _, s := range line.Buttons {
-		btn := NewButton(
-			s.Label,
-			s.Action,
-			vec.Rect{p, p.Add(vec.I2{65, 25})},
-			d.bubble.View)
+	// The first page is the fullest, so it decides the size.
+	ts := d.text.Size()
+	size := vec.I2{d.camSize.X - 20, chrome + ts.Y}
+	if line.Speaker != nil {
+		// Shrink to fit the contents.
+		w := ts.X
+		if btnSize.X > w {
+			w = btnSize.X
+		}
+		size.X = textPos.X + w + 10
+	} else if size.Y < dialogueMinSize {
+		size.Y = dialogueMinSize
+	}
+	if size.Y < minHeight {
+		size.Y = minHeight
+	}
+	d.SetSize(size)
+	d.bubble.SetSize(size)
+	d.reposition()
+
+	origin := vec.I2{textPos.X, size.Y - 10 - btnSize.Y}
+	for i, s := range line.Buttons {
+		bounds := vec.Rect{btnBounds[i].UL.Add(origin), btnBounds[i].DR.Add(origin)}
+		action := s.recordedAction()
+		var btn *Button
+		if s.LabelMsg != nil {
+			btn = NewMsgButton(s.LabelMsg, action, bounds, d.bubble.View)
+		} else {
+			btn = NewButton(s.Label, action, bounds, d.bubble.View)
+		}
 		d.buttons = append(d.buttons, btn)
-		p.X += 75
+	}
+	d.showButtons()
+
+	d.voice = 0
+	if line.Voice != "" {
+		playSound(line.Voice)
+		d.voice = audio.Duration(line.Voice)
+	}
+}
+
+// stopVoice stops the voice clip of the current line.
+func (d *DialogueDisplay) stopVoice() {
+	if d.line != nil && d.line.Voice != "" {
+		audio.Stop(d.line.Voice)
+	}
+}
+
+// lastPage reports whether the last page of the text is being shown.
+func (d *DialogueDisplay) lastPage() bool {
+	return d.text.Page >= d.text.Pages-1
+}
+
+// showButtons makes the buttons visible on the last page only.
+func (d *DialogueDisplay) showButtons() {
+	for _, b := range d.buttons {
+		b.Bubble.View.SetVisible(d.lastPage())
+	}
+}
+
+// nextPage lays out the next page of the text.
+func (d *DialogueDisplay) nextPage() {
+	d.text.Page++
+	d.text.SetSize(vec.I2{d.text.width, 0})
+	d.text.Layout(d.line.Slowness < 0)
+	d.text.AddToScene(scene)
+	d.complete = false
+	d.showButtons()
+}
+
+// reposition moves the display to its fixed place at the bottom of the screen,
+// or above the speaker of the current line.
+func (d *DialogueDisplay) reposition() {
+	if d.line == nil || d.line.Speaker == nil {
+		d.SetPosition(vec.I2{10, d.camSize.Y - 10 - d.Size().Y})
+		if d.tail != nil {
+			d.tail.SetVisible(false)
+		}
+		return
+	}
+	pos, tailX := anchorAbove(d.Size(), d.line.Speaker)
+	d.SetPosition(pos)
+	if d.tail != nil {
+		d.tail.x = tailX
+		d.tail.SetVisible(tailX >= 0)
 	}
 }
 
 func (d *DialogueDisplay) AddToScene(scene *Scene) {
+	if d.tail != nil && !d.tail.added {
+		d.tail.added = true
+		scene.AddPart(d.tail)
+	}
 	d.bubble.AddToScene(scene)
 	d.text.AddToScene(scene)
 	for _, b := range d.buttons {
@@ -142,24 +318,104 @@ func (d *DialogueDisplay) AddToScene(scene *Scene) {
 func (d *DialogueDisplay) finish() {
 	d.complete = true
 	for d.text.next < len(d.text.chars) {
-		d.text.Advance()
+		d.text.advance(false)
+	}
+}
+
+// setFocus focuses the i'th button, wrapping around.
+func (d *DialogueDisplay) setFocus(i int) {
+	n := len(d.buttons)
+	d.focus = (i%n + n) % n
+	for j, b := range d.buttons {
+		b.Focused = j == d.focus
 	}
 }
 
+// press activates the i'th button, if it exists.
+func (d *DialogueDisplay) press(i int) bool {
+	if i < 0 || i >= len(d.buttons) {
+		return false
+	}
+	if a := d.buttons[i].Action; a != nil {
+		a()
+	}
+	return true
+}
+
+// handleInput handles keyboard and gamepad input for the choices. Returns
+// true if a choice was made.
+func (d *DialogueDisplay) handleInput(in Input) bool {
+	if len(d.buttons) == 0 {
+		return false
+	}
+	switch in {
+	case InputPrev:
+		if d.focus < 0 {
+			d.setFocus(d.line.Default)
+		} else {
+			d.setFocus(d.focus - 1)
+		}
+	case InputNext:
+		if d.focus < 0 {
+			d.setFocus(d.line.Default)
+		} else {
+			d.setFocus(d.focus + 1)
+		}
+	case InputConfirm:
+		if d.focus < 0 {
+			d.setFocus(d.line.Default)
+			return false
+		}
+		return d.press(d.focus)
+	case InputCancel:
+		for i, s := range d.line.Buttons {
+			if s.Cancel {
+				return d.press(i)
+			}
+		}
+	}
+	return false
+}
+
 // Update updates things in the dialogue, based on user input or passage of time.
 // Returns true if the event is handled.
 func (d *DialogueDisplay) Handle(event *Event) bool {
-	for _, b := range d.buttons {
-		if b.Handle(event) {
-			// log.Printf("dialogue: button handled event")
+	if d.voice > 0 {
+		d.voice--
+	}
+	if d.complete && d.lastPage() {
+		if d.handleInput(event.Input) {
 			return true
 		}
+		if t := d.line.Timeout; t > 0 && len(d.buttons) > 0 {
+			if d.waited++; d.waited >= t {
+				return d.press(d.line.Default)
+			}
+		}
+	}
+	if d.lastPage() {
+		for _, b := range d.buttons {
+			if b.Handle(event) {
+				// log.Printf("dialogue: button handled event")
+				return true
+			}
+		}
 	}
 	if d.complete && d.line.AutoNext {
 		// log.Printf("dialogue: complete and autonext")
-		return true
+		if !d.lastPage() {
+			d.nextPage()
+			return false
+		}
+		if d.voice <= 0 {
+			return true
+		}
 	}
-	if event.Type == EventMouseUp {
+	if event.Type == EventMouseUp || event.Input == InputConfirm {
+		if d.complete && !d.lastPage() {
+			d.nextPage()
+			return false
+		}
 		if d.complete && len(d.buttons) == 0 {
 			// log.Printf("dialogue: clicked, complete, and no buttons")
 			return true
@@ -191,6 +447,7 @@ func playNextDialogue() {
 			if config.Debug {
 				log.Printf("disposing a dialogue")
 			}
+			dialogue.stopVoice()
 			dialogue.Dispose()
 		}
 		dialogue = nil
@@ -207,6 +464,7 @@ func playNextDialogue() {
 	}
 	dialogue.Layout(dialogueStack[0])
 	dialogue.AddToScene(scene)
+	record(TranscriptEntry{Speaker: dialogueStack[0].Name, Text: dialogue.text.Text})
 	dialogueStack = dialogueStack[1:]
 }
 
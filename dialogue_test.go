@@ -0,0 +1,125 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DrJosh9000/vec"
+)
+
+// choiceDisplay makes a display showing the line's choices, without any views.
+func choiceDisplay(line *DialogueLine, pressed *string) *DialogueDisplay {
+	d := &DialogueDisplay{line: line, text: &Text{Pages: 1}, focus: -1, complete: true}
+	for _, s := range line.Buttons {
+		s := s
+		d.buttons = append(d.buttons, &Button{Action: func() { *pressed = s.Label }})
+	}
+	return d
+}
+
+func TestDialogueChoiceNavigation(t *testing.T) {
+	line := &DialogueLine{
+		Buttons: []*ButtonSpec{{Label: "Yes"}, {Label: "No", Cancel: true}, {Label: "Maybe"}},
+		Default: 2,
+	}
+	var pressed string
+	d := choiceDisplay(line, &pressed)
+
+	steps := []struct {
+		in        Input
+		wantFocus int
+		wantPress string
+	}{
+		{InputConfirm, 2, ""}, // first confirm only focuses the default
+		{InputNext, 0, ""},
+		{InputNext, 1, ""},
+		{InputPrev, 0, ""},
+		{InputPrev, 2, ""},
+		{InputConfirm, 2, "Maybe"},
+	}
+	for i, s := range steps {
+		pressed = ""
+		got := d.handleInput(s.in)
+		if want := s.wantPress != ""; got != want {
+			t.Errorf("step %d: handleInput(%v) = %t, want %t", i, s.in, got, want)
+		}
+		if d.focus != s.wantFocus {
+			t.Errorf("step %d: focus = %d, want %d", i, d.focus, s.wantFocus)
+		}
+		if pressed != s.wantPress {
+			t.Errorf("step %d: pressed %q, want %q", i, pressed, s.wantPress)
+		}
+		for j, b := range d.buttons {
+			if b.Focused != (j == d.focus) {
+				t.Errorf("step %d: button %d Focused = %t", i, j, b.Focused)
+			}
+		}
+	}
+
+	pressed = ""
+	if !d.handleInput(InputCancel) || pressed != "No" {
+		t.Errorf("InputCancel pressed %q, want %q", pressed, "No")
+	}
+}
+
+func TestDialogueChoiceTimeout(t *testing.T) {
+	line := &DialogueLine{
+		Buttons: []*ButtonSpec{{Label: "Yes"}, {Label: "No"}},
+		Default: 1,
+		Timeout: 1,
+	}
+	var pressed string
+	d := choiceDisplay(line, &pressed)
+	if !d.Handle(&Event{}) {
+		t.Error("Handle after timeout = false, want true")
+	}
+	if want := "No"; pressed != want {
+		t.Errorf("after timeout pressed %q, want %q", pressed, want)
+	}
+}
+
+func TestLayoutButtons(t *testing.T) {
+	specs := []*ButtonSpec{{Label: "ab"}, {Label: "abcd"}, {Label: "abcdefgh"}}
+	bounds, size := layoutButtons(specs, newTestFont("abcdefgh"), 100)
+	want := []vec.Rect{
+		{vec.I2{0, 0}, vec.I2{38, 25}},
+		{vec.I2{48, 0}, vec.I2{94, 25}},
+		{vec.I2{0, 35}, vec.I2{62, 60}},
+	}
+	if !reflect.DeepEqual(bounds, want) {
+		t.Errorf("layoutButtons bounds = %v, want %v", bounds, want)
+	}
+	if want := (vec.I2{94, 60}); size != want {
+		t.Errorf("layoutButtons size = %v, want %v", size, want)
+	}
+}
+
+func TestDialogueWaitsForVoice(t *testing.T) {
+	d := &DialogueDisplay{
+		line:     &DialogueLine{AutoNext: true, Voice: "hello"},
+		text:     &Text{Pages: 1},
+		complete: true,
+		voice:    3,
+	}
+	var got []bool
+	for i := 0; i < 3; i++ {
+		got = append(got, d.Handle(&Event{}))
+	}
+	if want := []bool{false, false, true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Handle while voice plays = %v, want %v", got, want)
+	}
+}
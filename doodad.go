@@ -32,7 +32,8 @@ type Doodad struct {
 
 func (d *Doodad) Dst() (x0, y0, x1, y1 int) {
 	x0, y0 = d.P.Sub(d.Offset).C()
-	x1, y1 = x0+d.FrameSize.X, d.FrameSize.Y
+	sz := d.frameSize(d.FrameNo)
+	x1, y1 = x0+sz.X, y0+sz.Y
 	return
 }
 
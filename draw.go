@@ -16,6 +16,8 @@ package awakengine
 
 import (
 	"fmt"
+	"image/color"
+	"math"
 	"sort"
 
 	"github.com/DrJosh9000/vec"
@@ -34,6 +36,61 @@ type Part interface {
 	Z() int
 }
 
+// Tinted can optionally be implemented by a Part to modulate its colour.
+type Tinted interface {
+	// Tint returns the colour to multiply the part's colour by, or nil for none.
+	Tint() color.Color
+}
+
+// Transform describes how to draw a part beyond its Src and Dst rectangles.
+// The zero Transform draws it as is. Flips and scaling are about Pivot, as is
+// rotation.
+type Transform struct {
+	FlipH, FlipV   bool
+	ScaleX, ScaleY float64 // 0 means 1
+	Rotation       float64 // radians clockwise
+	Pivot          vec.I2  // relative to the top-left of Dst
+}
+
+// Transformed can optionally be implemented by a Part to be flipped, scaled, or
+// rotated. The colour (including alpha, for fading) is from Tint. Parts that
+// aren't rotated are batched as usual; each rotated part is a draw call.
+type Transformed interface {
+	Tinted
+	Transform() Transform
+}
+
+// partTransform returns the transform of a part.
+func partTransform(p Part) Transform {
+	if t, ok := p.(Transformed); ok {
+		return t.Transform()
+	}
+	return Transform{}
+}
+
+// Faded returns a tint that only changes alpha, for fading parts in or out.
+func Faded(alpha float64) color.Color {
+	return color.NRGBA{0xff, 0xff, 0xff, uint8(alpha*0xff + 0.5)}
+}
+
+// partTint returns the tint of a part, or nil.
+func partTint(p Part) color.Color {
+	if t, ok := p.(Tinted); ok {
+		return t.Tint()
+	}
+	return nil
+}
+
+// sameColour reports whether two (possibly nil) colours are the same.
+func sameColour(a, b color.Color) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	return ar == br && ag == bg && ab == bb && aa == ba
+}
+
 // drawPosition adjusts the source rectangle to refer to the texture atlas, and
 // destination rectangle to offset from the containing view.
 type drawPosition struct{ Part }
@@ -46,16 +103,73 @@ func (p drawPosition) Dst() (x0, y0, x1, y1 int) {
 		return
 	}
 	o := c.Position()
-	return x0 + o.X, y0 + o.Y, x1 + o.X, y1 + o.Y
+	x0, y0, x1, y1 = x0+o.X, y0+o.Y, x1+o.X, y1+o.Y
+	t := partTransform(p.Part)
+	if t == (Transform{}) {
+		return
+	}
+	// Flip and scale by moving the edges; a backwards rectangle draws mirrored.
+	pv := vec.I2{x0, y0}.Add(t.Pivot)
+	x0, x1 = scaleAbout(x0, pv.X, t.ScaleX, t.FlipH), scaleAbout(x1, pv.X, t.ScaleX, t.FlipH)
+	y0, y1 = scaleAbout(y0, pv.Y, t.ScaleY, t.FlipV), scaleAbout(y1, pv.Y, t.ScaleY, t.FlipV)
+	return
+}
+
+// canonRect makes a rectangle from corners that may be backwards (flipped).
+func canonRect(x0, y0, x1, y1 int) vec.Rect {
+	if x0 > x1 {
+		x0, x1 = x1, x0
+	}
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
+	return vec.NewRect(x0, y0, x1, y1)
 }
 
+// scaleAbout scales the distance of x from p by s (unless s is 0), negated if
+// flip is set.
+func scaleAbout(x, p int, s float64, flip bool) int {
+	if s == 0 {
+		s = 1
+	}
+	if flip {
+		s = -s
+	}
+	return p + int(math.Floor(float64(x-p)*s+0.5))
+}
+
+// pivot returns the screen position of the part's pivot.
+func (p drawPosition) pivot() vec.I2 {
+	x0, y0, _, _ := p.Part.Dst()
+	return vec.I2{x0, y0}.Add(p.Part.Container().Position()).Add(partTransform(p.Part).Pivot)
+}
+
+// aboutPivot is a part with Dst relative to a pivot, to be rotated.
+type aboutPivot struct {
+	drawPosition
+	pv vec.I2
+}
+
+func (a aboutPivot) Len() int { return 1 }
+func (a aboutPivot) Dst(int) (x0, y0, x1, y1 int) {
+	x0, y0, x1, y1 = a.drawPosition.Dst()
+	return x0 - a.pv.X, y0 - a.pv.Y, x1 - a.pv.X, y1 - a.pv.Y
+}
+func (a aboutPivot) Src(int) (x0, y0, x1, y1 int) { return a.drawPosition.Src() }
+
 func (p drawPosition) Src() (x0, y0, x1, y1 int) {
 	x0, y0, x1, y1 = p.Part.Src()
-	o, ok := compositeOffset[p.Part.ImageKey()]
+	o := p.place().pos
+	return x0 + o.X, y0 + o.Y, x1 + o.X, y1 + o.Y
+}
+
+// place is where the part's image is in the texture atlas.
+func (p drawPosition) place() atlasPlace {
+	pl, ok := atlasPlaces[p.Part.ImageKey()]
 	if !ok {
 		panic(fmt.Sprintf("unknown image key %q", p.Part.ImageKey()))
 	}
-	return x0 + o.X, y0 + o.Y, x1 + o.X, y1 + o.Y
+	return pl
 }
 
 // drawList is a Z-sortable list of objects in texture atlas/screen coordinates.
@@ -86,13 +200,17 @@ func (d drawList) subslice(dst drawList, keep func(Part) bool) drawList {
 
 // cull removes invisible objects and places visible objects in dst. dst can be d[:0].
 // Visibility is determined by calling Visible() and by testing the Dst rectangle.
+// Parts whose images are waiting to be placed in the atlas aren't drawn yet.
 func (d drawList) cull(dst drawList, scene *Scene) drawList {
 
 	return d.subslice(dst, func(p Part) bool {
 		if !p.Visible() {
 			return false
 		}
-		if r := vec.NewRect(p.Dst()); !r.Overlaps(scene.View.Bounds()) {
+		if _, placed := atlasPlaces[p.ImageKey()]; !placed && atlasPending[p.ImageKey()] {
+			return false
+		}
+		if r := canonRect(p.Dst()); !r.Overlaps(scene.View.Bounds()) {
 			return false
 		}
 		return true
@@ -123,8 +241,45 @@ func merge(dst, a, b drawList) drawList {
 	return dst
 }
 
-func (d drawList) draw(screen *ebiten.Image) error {
-	return screen.DrawImage(composite, &ebiten.DrawImageOptions{
-		ImageParts: d,
-	})
+// draw draws the list with one draw call per run of parts on the same atlas
+// page with the same tint, plus one per rotated part. Parts aren't reordered
+// across runs, so Z order is kept. Parts within the zoomed views are magnified
+// by zoom.
+func (d drawList) draw(screen *ebiten.Image, zoomed []*View, zoom int) error {
+	inZoom := func(p drawPosition) bool {
+		for _, v := range zoomed {
+			if p.Container().within(v) {
+				return true
+			}
+		}
+		return false
+	}
+	for len(d) > 0 {
+		pg, t, z := d[0].place().page, partTint(d[0].Part), inZoom(d[0])
+		op := &ebiten.DrawImageOptions{ImageParts: d[:1]}
+		n := 1
+		if r := partTransform(d[0].Part).Rotation; r != 0 {
+			pv := d[0].pivot()
+			op.ImageParts = aboutPivot{d[0], pv}
+			op.GeoM.Rotate(r)
+			op.GeoM.Translate(float64(pv.X), float64(pv.Y))
+		} else {
+			for n < len(d) && d[n].place().page == pg && sameColour(partTint(d[n].Part), t) && partTransform(d[n].Part).Rotation == 0 && inZoom(d[n]) == z {
+				n++
+			}
+			op.ImageParts = d[:n]
+		}
+		if z {
+			op.GeoM.Scale(float64(zoom), float64(zoom))
+		}
+		if t != nil {
+			c := color.NRGBAModel.Convert(t).(color.NRGBA)
+			op.ColorM.Scale(float64(c.R)/0xff, float64(c.G)/0xff, float64(c.B)/0xff, float64(c.A)/0xff)
+		}
+		if err := screen.DrawImage(atlasPages[pg], op); err != nil {
+			return err
+		}
+		d = d[n:]
+	}
+	return nil
 }
@@ -0,0 +1,69 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/DrJosh9000/vec"
+)
+
+// transformedPart is a 10x20 part at (5, 5) in its view.
+type transformedPart struct {
+	*View
+	t Transform
+}
+
+func (p *transformedPart) ImageKey() string          { return "test" }
+func (p *transformedPart) Src() (x0, y0, x1, y1 int) { return 0, 0, 10, 20 }
+func (p *transformedPart) Dst() (x0, y0, x1, y1 int) { return 5, 5, 15, 25 }
+func (p *transformedPart) Tint() color.Color         { return nil }
+func (p *transformedPart) Transform() Transform      { return p.t }
+
+func TestTransformedDst(t *testing.T) {
+	v := &View{}
+	v.SetPosition(vec.I2{100, 100})
+	for _, test := range []struct {
+		t    Transform
+		want vec.Rect
+	}{
+		{Transform{}, vec.NewRect(105, 105, 115, 125)},
+		{Transform{FlipH: true}, vec.NewRect(105, 105, 95, 125)}, // about the top-left
+		{Transform{FlipH: true, Pivot: vec.I2{5, 20}}, vec.NewRect(115, 105, 105, 125)},
+		{Transform{FlipV: true, Pivot: vec.I2{5, 20}}, vec.NewRect(105, 145, 115, 125)},
+		{Transform{ScaleX: 2, ScaleY: 0.5, Pivot: vec.I2{5, 20}}, vec.NewRect(100, 115, 120, 125)},
+		{Transform{Rotation: 1, Pivot: vec.I2{5, 20}}, vec.NewRect(105, 105, 115, 125)},
+	} {
+		p := drawPosition{&transformedPart{v, test.t}}
+		if got := vec.NewRect(p.Dst()); got != test.want {
+			t.Errorf("Dst with %+v = %v, want %v", test.t, got, test.want)
+		}
+	}
+
+	p := drawPosition{&transformedPart{v, Transform{FlipH: true, Pivot: vec.I2{5, 20}}}}
+	if got, want := p.pivot(), (vec.I2{110, 125}); got != want {
+		t.Errorf("pivot() = %v, want %v", got, want)
+	}
+	if got, want := canonRect(p.Dst()), vec.NewRect(105, 105, 115, 125); got != want {
+		t.Errorf("canonRect(Dst) = %v, want %v", got, want)
+	}
+}
+
+func TestFaded(t *testing.T) {
+	if got, want := Faded(0.5), (color.NRGBA{0xff, 0xff, 0xff, 0x80}); got != want {
+		t.Errorf("Faded(0.5) = %v, want %v", got, want)
+	}
+}
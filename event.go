@@ -14,7 +14,10 @@
 
 package awakengine
 
-import "github.com/DrJosh9000/vec"
+import (
+	"github.com/DrJosh9000/vec"
+	"github.com/hajimehoshi/ebiten"
+)
 
 type EventType int
 
@@ -30,4 +33,76 @@ type Event struct {
 	ScreenPos vec.I2 // 0,0 is top left of screen.
 	WorldPos  vec.I2 // 0,0 is origin of world.
 	MouseDown bool
+	Input     Input // input newly pressed this update, if any
+}
+
+// Input is a non-pointer input, such as from a keyboard or gamepad.
+type Input int
+
+const (
+	InputNone    = Input(iota)
+	InputPrev    // move focus to the previous choice
+	InputNext    // move focus to the next choice
+	InputConfirm // press the focused choice, or dismiss dialogue
+	InputCancel  // press the cancel choice
+)
+
+// KeyBindings are the keys for each Input. Games may change them.
+var KeyBindings = map[Input][]ebiten.Key{
+	InputPrev:    {ebiten.KeyLeft, ebiten.KeyUp},
+	InputNext:    {ebiten.KeyRight, ebiten.KeyDown},
+	InputConfirm: {ebiten.KeyEnter, ebiten.KeySpace},
+	InputCancel:  {ebiten.KeyEscape, ebiten.KeyBackspace},
+}
+
+// GamepadBindings are the gamepad buttons for each Input. The defaults follow
+// the standard layout: A confirms, B cancels, and the d-pad moves focus. The
+// left stick also moves focus.
+var GamepadBindings = map[Input][]ebiten.GamepadButton{
+	InputPrev:    {ebiten.GamepadButton12, ebiten.GamepadButton14},
+	InputNext:    {ebiten.GamepadButton13, ebiten.GamepadButton15},
+	InputConfirm: {ebiten.GamepadButton0},
+	InputCancel:  {ebiten.GamepadButton1},
+}
+
+// stickThreshold is how far the stick must be pushed to count as pressed.
+const stickThreshold = 0.5
+
+var lastInputs = make(map[Input]bool)
+
+// inputPressed reports whether any binding for in is held down.
+func inputPressed(in Input) bool {
+	for _, k := range KeyBindings[in] {
+		if ebiten.IsKeyPressed(k) {
+			return true
+		}
+	}
+	for _, b := range GamepadBindings[in] {
+		if ebiten.IsGamepadButtonPressed(0, b) {
+			return true
+		}
+	}
+	if ebiten.GamepadAxisNum(0) > 1 {
+		x, y := ebiten.GamepadAxis(0, 0), ebiten.GamepadAxis(0, 1)
+		switch in {
+		case InputPrev:
+			return x < -stickThreshold || y < -stickThreshold
+		case InputNext:
+			return x > stickThreshold || y > stickThreshold
+		}
+	}
+	return false
+}
+
+// readInput returns the first input that was pressed since the last call.
+func readInput() Input {
+	pressed := InputNone
+	for in := InputPrev; in <= InputCancel; in++ {
+		p := inputPressed(in)
+		if p && !lastInputs[in] && pressed == InputNone {
+			pressed = in
+		}
+		lastInputs[in] = p
+	}
+	return pressed
 }
@@ -14,9 +14,15 @@
 
 package awakengine
 
-import "github.com/DrJosh9000/vec"
+import (
+	"image/color"
+	"math"
 
-type CharMetrics map[byte]CharInfo
+	"github.com/DrJosh9000/vec"
+)
+
+// CharMetrics maps characters to the glyph information for each.
+type CharMetrics map[rune]CharInfo
 
 type Font interface {
 	ImageKey(invert bool) string
@@ -25,44 +31,155 @@ type Font interface {
 	YOffset() int
 }
 
+// PagedFont can optionally be implemented by a Font with glyphs spread over
+// several images. CharInfo.Page selects which image a glyph is on.
+type PagedFont interface {
+	PageKey(page int, invert bool) string
+}
+
+// BoldFonter can optionally be implemented by a Game to provide the font for
+// [b] markup in text created by the engine (dialogue, buttons, etc).
+type BoldFonter interface {
+	BoldFont() Font
+}
+
+// gameBoldFont returns the game's bold font, or nil.
+func gameBoldFont() Font {
+	if b, ok := game.(BoldFonter); ok {
+		return b.BoldFont()
+	}
+	return nil
+}
+
+// KerningFont can optionally be implemented by a Font with kerning pairs.
+type KerningFont interface {
+	// Kern returns the adjustment to the advance between a and b.
+	Kern(a, b rune) int
+}
+
+// FallbackFont can optionally be implemented by a Font to choose the glyph drawn
+// for characters missing from its metrics. Otherwise, U+FFFD or '?' are used if
+// the font has them.
+type FallbackFont interface {
+	Fallback() rune
+}
+
 type CharInfo struct {
 	X, Y, Width, Height, XOffset, YOffset, XAdvance int
+	Page                                            int // see PagedFont
+}
+
+// glyph returns the metrics to use for c in font f, substituting a fallback
+// glyph if c is missing. If there's no fallback either, the glyph is empty.
+func glyph(f Font, m CharMetrics, c rune) CharInfo {
+	if ci, ok := m[c]; ok {
+		return ci
+	}
+	if ff, ok := f.(FallbackFont); ok {
+		if ci, ok := m[ff.Fallback()]; ok {
+			return ci
+		}
+	}
+	if ci, ok := m['\uFFFD']; ok {
+		return ci
+	}
+	return m['?']
+}
+
+// fontImageKey returns the image key for a page of the font.
+func fontImageKey(f Font, page int, invert bool) string {
+	if pf, ok := f.(PagedFont); ok {
+		return pf.PageKey(page, invert)
+	}
+	return f.ImageKey(invert)
 }
 
 type oneChar struct {
 	*Text
+	font    Font
 	pos     vec.I2
-	c       byte
+	c       rune
+	ci      CharInfo
+	i       int // index within the text, for effects
+	style   textStyle
+	icon    *SheetFrame
+	pause   int
 	visible bool
 	retire  bool
 }
 
-func (s *oneChar) ImageKey() string { return s.Text.Font.ImageKey(s.Text.Invert) }
+func (s *oneChar) ImageKey() string {
+	if s.icon != nil {
+		return s.icon.ImageKey()
+	}
+	return fontImageKey(s.font, s.ci.Page, s.Text.Invert)
+}
 
 func (s *oneChar) Src() (x0, y0, x1, y1 int) {
-	m := s.Text.Metrics()
-	ci := m[s.c]
+	if s.icon != nil {
+		return s.icon.Src()
+	}
+	ci := s.ci
 	return ci.X, ci.Y, ci.X + ci.Width, ci.Y + ci.Height
 }
 
 func (s *oneChar) Dst() (x0, y0, x1, y1 int) {
-	m := s.Text.Metrics()
-	ci := m[s.c]
-	x0, y0 = s.pos.X+ci.XOffset, s.pos.Y+ci.YOffset+s.Text.YOffset()
+	p := s.pos.Add(s.effectOffset())
+	if s.icon != nil {
+		sz := s.icon.FrameSize
+		x0, y0 = p.X, p.Y+(s.font.LineHeight()-sz.Y)/2
+		return x0, y0, x0 + sz.X, y0 + sz.Y
+	}
+	ci := s.ci
+	x0, y0 = p.X+ci.XOffset, p.Y+ci.YOffset+s.font.YOffset()
 	return x0, y0, x0 + ci.Width, y0 + ci.Height
 }
 
+// effectOffset is the displacement due to wave and shake effects.
+func (s *oneChar) effectOffset() (d vec.I2) {
+	if s.style.wave {
+		d.Y += int(math.Floor(1.5*math.Sin(float64(displayFrame)/6+float64(s.i)/2) + 0.5))
+	}
+	if s.style.shake {
+		h := uint(displayFrame/2*31+s.i*17) * 2654435761
+		d.X += int(h>>16%3) - 1
+		d.Y += int(h>>24%3) - 1
+	}
+	return d
+}
+
+// Tint implements Tinted.
+func (s *oneChar) Tint() color.Color { return s.style.colour }
+
 func (s *oneChar) Visible() bool { return s.visible && s.Text.View.Visible() }
 func (s *oneChar) Retire() bool  { return s.retire || s.Text.View.Retire() }
 
+// Text displays text, which may contain markup (see parseMarkup for the tags).
 type Text struct {
 	*View
 	Font
-	Text   string
-	Invert bool
-	chars  []*oneChar
-	next   int
-	added  bool
+	BoldFont Font // used for [b] markup; Font is used if nil
+	Align    Alignment
+	VAlign   VAlignment
+	MaxLines int  // if positive, text beyond MaxLines is replaced with an ellipsis, unless Paged
+	Paged    bool // show MaxLines lines at a time, starting at line Page*MaxLines
+	Page     int
+	Text     string
+	Msg      *Msg // if set, Text is replaced with the localized message on Layout
+	Invert   bool
+	Blip     string // sound played as Advance reveals each character
+	chars    []*oneChar
+	next     int
+	wait     int // pause progress before revealing chars[next]
+	added    bool
+	width    int // width wrapped to by the last Layout
+	height   int // height aligned within by the last Layout
+
+	// Truncated is set by Layout if the text didn't fit in MaxLines.
+	Truncated bool
+	// Pages is set by Layout to the number of pages of Paged text, or 1.
+	Pages  int
+	relaid func() // called after relocalizing
 }
 
 func (t *Text) AddToScene(s *Scene) {
@@ -75,78 +192,113 @@ func (t *Text) AddToScene(s *Scene) {
 	}
 }
 
-// Advance makes the next character visible.
+// Advance makes the next character visible, unless there is a pause before it.
 func (t *Text) Advance() error {
+	return t.advance(true)
+}
+
+// advance is Advance, with the blip optional.
+func (t *Text) advance(blip bool) error {
 	if t.next < len(t.chars) {
+		if c := t.chars[t.next]; t.wait < c.pause {
+			t.wait++
+			return nil
+		}
 		t.chars[t.next].visible = true
+		if blip {
+			playSound(t.Blip)
+		}
 	}
+	t.wait = 0
 	t.next++
 	return nil
 }
 
+// relocalize lays out the text again in the current locale, keeping the same
+// proportion of it visible.
+func (t *Text) relocalize() {
+	n, all := t.next, t.next >= len(t.chars)
+	t.View.SetSize(vec.I2{t.width, t.height})
+	t.Layout(all)
+	if !all {
+		for t.next < n && t.next < len(t.chars) {
+			t.Advance()
+		}
+	}
+	t.AddToScene(scene)
+	if t.relaid != nil {
+		t.relaid()
+	}
+}
+
 // Layout causes the text to lay out all the characters, and update
 // the size to exactly contain the text. Text will be wrapped to the
-// existing Size.X as a width.
+// existing Size.X as a width (unless it is 0). If Align is not AlignLeft, the
+// width is kept; if VAlign is not VAlignTop, the existing Size.Y is kept as
+// the height to align within (if the text fits).
 func (t *Text) Layout(visible bool) {
+	if t.Msg != nil {
+		t.Text = t.Msg.String()
+		localizedTexts[t] = Locale()
+	} else {
+		delete(localizedTexts, t)
+	}
+
 	// Reset things
 	t.added = false
 	for i := range t.chars {
 		t.chars[i].retire = true
 	}
 	t.chars = t.chars[:0]
-	t.next = 0
+	t.next, t.wait = 0, 0
 
 	// Compute new characters.
-	width := t.View.Size().X
-	maxW := 0
-	cm := t.Metrics()
-	x, y := 0, 0
-	wordStartC, wordStartI := 0, 0 // chars index, Text index
-	wrapIt := func(end int) {
-		if x < width {
-			return
-		}
-		if x > maxW {
-			maxW = x
-		}
-		x = 0
-		y += t.LineHeight()
-		// Fix previous word.
-		for i, j := wordStartC, wordStartI; j < end; i, j = i+1, j+1 {
-			c := t.Text[j]
-			ci := cm[c]
-			t.chars[i].pos = vec.I2{x, y}
-			x += ci.XAdvance
-		}
+	box := t.View.Size()
+	t.width, t.height = box.X, box.Y
+	bold := t.BoldFont
+	if bold == nil {
+		bold = t.Font
 	}
-	for i := range t.Text {
-		if t.Text[i] == '\n' {
-			x = 0
-			y += t.LineHeight()
-			wordStartC = len(t.chars)
-			wordStartI = i + 1
-			continue
+	items := parseMarkup(t.Text)
+	maxLines := t.MaxLines
+	if t.Paged {
+		maxLines = 0
+	}
+	l := layoutText(t.Font, bold, items, box.X, maxLines, t.Align)
+	t.Truncated = l.truncated
+	t.Pages = 1
+	if t.Paged && t.MaxLines > 0 {
+		t.Pages = (len(l.lines) + t.MaxLines - 1) / t.MaxLines
+		if t.Page >= t.Pages {
+			t.Page = t.Pages - 1
 		}
-		c := t.Text[i]
-		ci := cm[c]
-		if t.Text[i] == ' ' {
-			wrapIt(i)
-			wordStartC = len(t.chars)
-			wordStartI = i + 1
-			x += ci.XAdvance
-			continue
+		l.page(t.Page*t.MaxLines, t.MaxLines, t.Font.LineHeight())
+	}
+	size := l.size
+	dy := 0
+	if t.VAlign != VAlignTop && box.Y > size.Y {
+		dy = box.Y - size.Y
+		if t.VAlign == VAlignMiddle {
+			dy /= 2
 		}
-		t.chars = append(t.chars, &oneChar{
+		size.Y = box.Y
+	}
+	for _, g := range l.glyphs {
+		c := &oneChar{
 			Text:    t,
-			pos:     vec.I2{x, y},
-			c:       c,
+			font:    g.font,
+			pos:     g.pos.Add(vec.I2{0, dy}),
+			c:       g.c,
+			ci:      g.ci,
+			i:       g.item,
+			icon:    g.icon,
 			visible: visible,
-		})
-		x += ci.XAdvance
-	}
-	wrapIt(len(t.Text))
-	if x > maxW {
-		maxW = x
+		}
+		if g.item < len(items) {
+			c.style = items[g.item].style
+			c.pause = items[g.item].pause
+		}
+		t.chars = append(t.chars, c)
 	}
-	t.View.SetSize(vec.I2{maxW, y + t.LineHeight()})
+	t.View.SetSize(size)
 }
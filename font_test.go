@@ -0,0 +1,310 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"fmt"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DrJosh9000/vec"
+)
+
+// testFont is a monospaced font where every glyph is 4 pixels wide. Glyphs for
+// runes >= 0x100 are on page 1.
+type testFont struct {
+	metrics  CharMetrics
+	fallback rune
+}
+
+func newTestFont(chars string) *testFont {
+	f := &testFont{metrics: make(CharMetrics)}
+	for i, c := range []rune(chars) {
+		ci := CharInfo{X: i * 4, Width: 4, Height: 8, XAdvance: 4}
+		if c >= 0x100 {
+			ci.Page = 1
+		}
+		f.metrics[c] = ci
+	}
+	return f
+}
+
+func (f *testFont) ImageKey(invert bool) string { return f.PageKey(0, invert) }
+func (f *testFont) Metrics() CharMetrics        { return f.metrics }
+func (f *testFont) LineHeight() int             { return 10 }
+func (f *testFont) YOffset() int                { return 0 }
+func (f *testFont) Fallback() rune              { return f.fallback }
+
+func (f *testFont) PageKey(page int, invert bool) string {
+	if page == 1 {
+		return "font-page1"
+	}
+	return "font"
+}
+
+func TestTextLayoutUTF8(t *testing.T) {
+	f := newTestFont("abcé ∑?")
+	f.fallback = '∑'
+	text := &Text{View: &View{}, Font: f, Text: "aé∑ cü"}
+	text.View.SetSize(vec.I2{100, 0})
+	text.Layout(true)
+
+	if got, want := len(text.chars), 5; got != want {
+		t.Fatalf("Got %d chars, want %d", got, want)
+	}
+	wants := []struct {
+		c    rune
+		x    int
+		key  string
+		srcX int
+	}{
+		{'a', 0, "font", 0},
+		{'é', 4, "font", 12},
+		{'∑', 8, "font-page1", 20},
+		{'c', 16, "font", 8},
+		{'ü', 20, "font-page1", 20}, // fallback glyph
+	}
+	for i, w := range wants {
+		ch := text.chars[i]
+		if ch.c != w.c || ch.pos.X != w.x {
+			t.Errorf("chars[%d] = %q at x=%d, want %q at x=%d", i, ch.c, ch.pos.X, w.c, w.x)
+		}
+		if got := ch.ImageKey(); got != w.key {
+			t.Errorf("chars[%d].ImageKey() = %q, want %q", i, got, w.key)
+		}
+		if x0, _, _, _ := ch.Src(); x0 != w.srcX {
+			t.Errorf("chars[%d].Src() x0 = %d, want %d", i, x0, w.srcX)
+		}
+	}
+	if got, want := text.View.Size(), (vec.I2{24, 10}); got != want {
+		t.Errorf("Got size %v, want %v", got, want)
+	}
+}
+
+func TestParseMarkup(t *testing.T) {
+	icon := &SheetFrame{Sheet: &Sheet{Key: "icons", FrameSize: vec.I2{8, 8}}}
+	RegisterIcon("key", icon)
+	red := color.NRGBA{0xff, 0, 0, 0xff}
+
+	items := parseMarkup("a[color=#f00]b[b]c[/b][/color] [[x] [pause=3][icon=key][wave]d[/wave][nope]")
+	var got []string
+	for _, it := range items {
+		s := string(it.c)
+		if it.icon != nil {
+			s = "icon"
+		}
+		if it.style.colour != nil {
+			if it.style.colour != color.Color(red) {
+				t.Errorf("Item %q has colour %v, want %v", s, it.style.colour, red)
+			}
+			s += "+red"
+		}
+		if it.style.bold {
+			s += "+b"
+		}
+		if it.style.wave {
+			s += "+wave"
+		}
+		if it.pause > 0 {
+			s += fmt.Sprintf("+pause%d", it.pause)
+		}
+		got = append(got, s)
+	}
+	want := "a b+red c+red+b   [ x ]   icon+pause3 d+wave [ n o p e ]"
+	if g := strings.Join(got, " "); g != want {
+		t.Errorf("parseMarkup items:\ngot  %q\nwant %q", g, want)
+	}
+}
+
+func TestTextAdvancePause(t *testing.T) {
+	text := &Text{View: &View{}, Font: newTestFont("ab"), Text: "a[pause=2]b"}
+	text.View.SetSize(vec.I2{100, 0})
+	text.Layout(false)
+	var got []bool
+	for i := 0; i < 4; i++ {
+		text.Advance()
+		got = append(got, text.chars[1].visible)
+	}
+	if want := []bool{false, false, false, true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Visibility of b after each Advance = %v, want %v", got, want)
+	}
+}
+
+// kernedFont is a testFont with kerning between 'a' and 'b'.
+type kernedFont struct{ *testFont }
+
+func (kernedFont) Kern(a, b rune) int {
+	if a == 'a' && b == 'b' {
+		return -1
+	}
+	return 0
+}
+
+func TestMeasureText(t *testing.T) {
+	f := newTestFont("abc .…")
+	tests := []struct {
+		font  Font
+		s     string
+		width int
+		want  vec.I2
+	}{
+		{f, "abc", 0, vec.I2{12, 10}},
+		{f, "abc abc abc", 0, vec.I2{44, 10}},
+		{f, "abc abc abc", 30, vec.I2{28, 20}},
+		{f, "abc abc abc", 27, vec.I2{12, 30}},
+		{f, "abc\n\nab", 100, vec.I2{12, 30}},
+		{f, "[b]abc[/b] [icon=key]", 0, vec.I2{25, 10}},
+		{kernedFont{f}, "abab", 0, vec.I2{14, 10}},
+	}
+	RegisterIcon("key", &SheetFrame{Sheet: &Sheet{Key: "icons", FrameSize: vec.I2{8, 8}}})
+	for _, test := range tests {
+		if got := MeasureText(test.font, test.s, test.width); got != test.want {
+			t.Errorf("MeasureText(%q, %d) = %v, want %v", test.s, test.width, got, test.want)
+		}
+	}
+}
+
+// glyphXs returns the x positions of the glyphs on each line.
+func glyphXs(text *Text) [][]int {
+	var xs [][]int
+	for _, c := range text.chars {
+		l := c.pos.Y / text.LineHeight()
+		for len(xs) <= l {
+			xs = append(xs, nil)
+		}
+		xs[l] = append(xs[l], c.pos.X)
+	}
+	return xs
+}
+
+func TestTextAlignment(t *testing.T) {
+	f := newTestFont("abc .…")
+	tests := []struct {
+		align Alignment
+		want  [][]int
+	}{
+		{AlignLeft, [][]int{{0, 4, 16}, {0, 4}}},
+		{AlignCentre, [][]int{{5, 9, 21}, {11, 15}}},
+		{AlignRight, [][]int{{10, 14, 26}, {22, 26}}},
+		{AlignJustify, [][]int{{0, 4, 26}, {0, 4}}},
+	}
+	for _, test := range tests {
+		text := &Text{View: &View{}, Font: f, Text: "ab  c ab", Align: test.align}
+		text.View.SetSize(vec.I2{30, 0})
+		text.Layout(true)
+		if got := glyphXs(text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Align %d: got glyph positions %v, want %v", test.align, got, test.want)
+		}
+		if got, want := text.View.Size().X, 30; test.align != AlignLeft && got != want {
+			t.Errorf("Align %d: got width %d, want %d", test.align, got, want)
+		}
+	}
+
+	text := &Text{View: &View{}, Font: f, Text: "a", VAlign: VAlignBottom}
+	text.View.SetSize(vec.I2{30, 25})
+	text.Layout(true)
+	if got, want := text.chars[0].pos.Y, 15; got != want {
+		t.Errorf("VAlignBottom: got y %d, want %d", got, want)
+	}
+	if got, want := text.View.Size(), (vec.I2{4, 25}); got != want {
+		t.Errorf("VAlignBottom: got size %v, want %v", got, want)
+	}
+}
+
+func TestTextMaxLines(t *testing.T) {
+	for _, test := range []struct {
+		chars, want string
+	}{
+		{"abc .…", "abc abc…"},
+		{"abc .", "abc ab..."},
+	} {
+		text := &Text{View: &View{}, Font: newTestFont(test.chars), Text: "abc abc abc abc", MaxLines: 2}
+		text.View.SetSize(vec.I2{20, 0})
+		text.Layout(true)
+		if !text.Truncated {
+			t.Errorf("Truncated = false, want true")
+		}
+		var got []rune
+		for i, c := range text.chars {
+			if i > 0 && c.pos.Y != text.chars[i-1].pos.Y {
+				got = append(got, ' ')
+			}
+			got = append(got, c.c)
+		}
+		if string(got) != test.want {
+			t.Errorf("With chars %q, got truncated text %q, want %q", test.chars, string(got), test.want)
+		}
+		if got, want := text.View.Size().Y, 20; got != want {
+			t.Errorf("Got height %d, want %d", got, want)
+		}
+	}
+}
+
+func TestTextPaged(t *testing.T) {
+	text := &Text{View: &View{}, Font: newTestFont("abcdefghij"), Text: "ab cd ef gh ij", MaxLines: 2, Paged: true}
+	for _, test := range []struct {
+		page   int
+		want   string
+		height int
+	}{
+		{0, "abcd efgh", 20},
+		{1, "ij", 10},
+		{5, "ij", 10}, // clamped to the last page
+	} {
+		text.Page = test.page
+		text.View.SetSize(vec.I2{20, 0})
+		text.Layout(true)
+		if got, want := text.Pages, 2; got != want {
+			t.Errorf("Page %d: got Pages %d, want %d", test.page, got, want)
+		}
+		if text.Truncated {
+			t.Errorf("Page %d: Truncated = true, want false", test.page)
+		}
+		var got []rune
+		for i, c := range text.chars {
+			if i > 0 && c.pos.Y != text.chars[i-1].pos.Y {
+				got = append(got, ' ')
+			}
+			got = append(got, c.c)
+		}
+		if string(got) != test.want {
+			t.Errorf("Page %d: got text %q, want %q", test.page, string(got), test.want)
+		}
+		if got := text.chars[0].pos.Y; got != 0 {
+			t.Errorf("Page %d: first line at y %d, want 0", test.page, got)
+		}
+		if got := text.View.Size().Y; got != test.height {
+			t.Errorf("Page %d: got height %d, want %d", test.page, got, test.height)
+		}
+	}
+}
+
+func TestTextBlip(t *testing.T) {
+	defer func(a Audio) { audio = a }(audio)
+	na := new(testAudio)
+	audio = na
+
+	text := &Text{View: &View{}, Font: newTestFont("abc"), Text: "ab c", Blip: "blip"}
+	text.Layout(false)
+	text.Advance()
+	text.Advance()
+	text.advance(false)
+	text.Advance() // past the end
+	if got, want := na.Played, []string{"blip", "blip"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Played = %q, want %q", got, want)
+	}
+}
@@ -59,6 +59,8 @@ var (
 	lastCursorPos vec.I2
 
 	terrain          *Terrain
+	level            *Level // the level terrain was loaded from
+	levelName        string // as passed to ChangeLevel
 	obstacles, paths *vec.Graph
 
 	dialogueStack []*DialogueLine
@@ -74,12 +76,16 @@ var (
 )
 
 type Config struct {
-	Debug           bool
-	FramesPerUpdate int
-	LevelGeomDump   string
-	LevelPreview    bool
-	RecordingFile   string
-	RecordingFrames int
+	Debug            bool
+	FireAllTriggers  bool // fire every eligible trigger each frame, not just the first
+	FramesPerUpdate  int
+	LevelGeomDump    string
+	LevelPreview     bool
+	RecordingFile    string
+	RecordingFrames  int
+	TriggerGraphDump string // file to write the trigger graph to, in Graphviz DOT format
+	AtlasDump        string // PNG file to write the packed texture atlas to; later pages get -1, -2, ...
+	TranscriptLimit  int    // most recent transcript entries to keep; all are kept if 0
 }
 
 // Handler handles events.
@@ -145,6 +151,12 @@ type Game interface {
 	Viewport() (pixelSize int, title string)
 }
 
+// LevelNamer can optionally be implemented by a Game to support ChangeLevel.
+type LevelNamer interface {
+	// LevelNamed provides the level with the given name.
+	LevelNamed(name string) (*Level, error)
+}
+
 // load prepares assets for use by the game.
 func load(g Game) error {
 	game = g
@@ -155,15 +167,28 @@ func load(g Game) error {
 	}
 
 	player, playerSprite = game.Player()
+	if a, ok := game.(AudioGame); ok {
+		audio = a.Audio()
+	}
 
 	trigs := game.Triggers()
+	if err := validateTriggers(trigs); err != nil {
+		return fmt.Errorf("validating triggers: %v", err)
+	}
+	if config.TriggerGraphDump != "" {
+		if err := dumpTriggerGraph(config.TriggerGraphDump, trigs); err != nil {
+			return fmt.Errorf("dumping trigger graph: %v", err)
+		}
+	}
+	trigs = append([]*Trigger(nil), trigs...)
+	sortTriggers(trigs)
 	triggersByName = make(map[string]*Trigger, len(trigs))
 	triggersByTile = make(map[vec.I2][]*Trigger, len(trigs))
-	for i, t := range trigs {
-		if t.Name == "" {
-			return fmt.Errorf("trigger %d has no name", i)
-		}
+	for _, t := range trigs {
 		triggersByName[t.Name] = t
+		if t.OnDemand {
+			continue
+		}
 		if len(t.Tiles) == 0 {
 			globalTriggers = append(globalTriggers, t)
 			continue
@@ -180,12 +205,30 @@ func load(g Game) error {
 	if err != nil {
 		return fmt.Errorf("loading level: %v", err)
 	}
+	if err := loadLevel(l); err != nil {
+		return err
+	}
+
+	if scene.Camera.Follow == nil {
+		scene.Camera.Follow = playerSprite
+	}
+	scene.Camera.Snap()
+	scene.sortFixedIfNeeded()
+	return nil
+}
 
+// loadLevel replaces the current terrain with one for the level l, and computes
+// the obstacles and paths for it (unless l provides them).
+func loadLevel(l *Level) error {
 	t, err := loadTerrain(l, scene.World)
 	if err != nil {
 		return fmt.Errorf("loading terrain: %v", err)
 	}
+	if terrain != nil {
+		terrain.View.Dispose()
+	}
 	terrain = t
+	level = l
 
 	obstacles, paths = l.Obstacles, l.Paths
 	if obstacles == nil || paths == nil || config.LevelGeomDump != "" {
@@ -210,15 +253,46 @@ func load(g Game) error {
 		}
 	}
 
-	//scene.CameraFocus(player.Pos())
 	terrain.AddToScene(scene)
 	if config.LevelPreview {
 		t.MakeAllVisible()
 	}
+	return nil
+}
+
+// ChangeLevel replaces the current level with the one provided by the game's
+// LevelNamed method. The game must implement LevelNamer.
+func ChangeLevel(name string) error {
+	ln, ok := game.(LevelNamer)
+	if !ok {
+		return fmt.Errorf("game %T does not implement LevelNamer", game)
+	}
+	l, err := ln.LevelNamed(name)
+	if err != nil {
+		return fmt.Errorf("loading level %q: %v", name, err)
+	}
+	if err := loadLevel(l); err != nil {
+		return err
+	}
+	levelName = name
+	lastPlayerTile = vec.I2{-1, -1}
+	scene.Camera.Snap()
 	scene.sortFixedIfNeeded()
 	return nil
 }
 
+func dumpTriggerGraph(path string, trigs []*Trigger) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := WriteTriggerGraph(f, trigs); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
 // Run runs the game (ebiten.Run) in addition to setting up any necessary GIF recording.
 func Run(g Game, cfg *Config) error {
 	config = cfg
@@ -275,32 +349,27 @@ func drawDebug(screen *ebiten.Image) error {
 }
 */
 
+// evaluateTriggers fires eligible triggers from the list, which should be in
+// priority order (see sortTriggers). Unless config.FireAllTriggers is set, only
+// the first eligible trigger fires. Otherwise every eligible trigger fires, in
+// order, in one pass: a trigger that depends on one later in the list can only
+// fire on a subsequent evaluation. Returns true if any trigger fired.
 func evaluateTriggers(triggers []*Trigger) bool {
-trigLoop:
+	fired := false
 	for _, trig := range triggers {
-		if trig.fired && !trig.Repeat {
-			continue
-		}
-		if trig.Active != nil && !trig.Active(modelFrame) {
+		if !trig.eligible() {
 			continue
 		}
-		// All dependencies fired?
-		for _, dep := range trig.Depends {
-			if !triggersByName[dep].fired {
-				continue trigLoop
-			}
-		}
 		if config.Debug {
 			log.Printf("firing %q", trig.Name)
 		}
-		if trig.Fire != nil {
-			trig.Fire(modelFrame)
+		trig.fire()
+		if !config.FireAllTriggers {
+			return true
 		}
-		//dialogueStack = trig.Dialogues
-		trig.fired = true
-		return true
+		fired = true
 	}
-	return false
+	return fired
 }
 
 func clientUpdate(e *Event) {
@@ -313,6 +382,9 @@ func clientUpdate(e *Event) {
 			u.Update(modelFrame)
 		}
 	}
+	if handleHotspots(e) {
+		return
+	}
 	game.Handle(e)
 }
 
@@ -331,8 +403,9 @@ func modelUpdate() {
 	e := &Event{
 		Time:      modelFrame,
 		ScreenPos: lastCursorPos,
-		WorldPos:  lastCursorPos.Sub(scene.World.Position()),
+		WorldPos:  scene.Camera.ScreenToWorld(lastCursorPos),
 		MouseDown: md,
+		Input:     readInput(),
 	}
 	switch {
 	case md && !mouseDown:
@@ -345,13 +418,17 @@ func modelUpdate() {
 	// TODO: propagate events along the view hierarchy...
 
 	// Do we proceed with the game, or with the dialogue display?
-	if dialogue == nil {
+	if backlog != nil {
+		// Everything waits while the backlog is read.
+		backlog.Handle(e)
+	} else if dialogue == nil {
 		// Got any triggers?
 		evaluateTriggers(globalTriggers)
 		clientUpdate(e)
 		if pt := terrain.TileCoord(playerSprite.Pos.I2()); pt != lastPlayerTile {
-			evaluateTriggers(triggersByTile[pt])
+			// Before evaluating, so a trigger that changes level can reset it.
 			lastPlayerTile = pt
+			evaluateTriggers(triggersByTile[pt])
 		}
 		if len(dialogueStack) > 0 {
 			//player.GoIdle() now in PushDialogue{,ToBack}
@@ -359,12 +436,23 @@ func modelUpdate() {
 		}
 		modelFrame++
 		terrain.UpdatePartVisibility(playerSprite.Pos.I2(), 5)
-	} else if dialogue.Handle(e) {
-		if len(dialogueStack) == 0 {
-			evaluateTriggers(globalTriggers)
+	} else {
+		dialogue.reposition()
+		if dialogue.Handle(e) {
+			if done := dialogue.line.Done; done != nil {
+				done()
+			}
+			if len(dialogueStack) == 0 {
+				evaluateTriggers(globalTriggers)
+			}
+			playNextDialogue()
 		}
-		playNextDialogue()
 	}
+	if backlog == nil {
+		scene.Camera.update()
+		updateTransition()
+	}
+	updateBarks()
 	scene.Update() // Reorganise draw lists
 }
 
@@ -374,12 +462,26 @@ func update(screen *ebiten.Image) error {
 	if displayFrame%config.FramesPerUpdate == 0 {
 		modelUpdate()
 	}
-	return scene.Draw(screen)
+	hotReload()
+	// A bad image stays pending (hot reloading may fix it) rather than
+	// stopping the game; parts using it draw its old image, or nothing.
+	if err := updateAtlas(); err != nil {
+		if msg := err.Error(); msg != atlasError {
+			log.Printf("updating atlas: %v", err)
+			atlasError = msg
+		}
+	} else {
+		atlasError = ""
+	}
+	if err := scene.Draw(screen); err != nil {
+		return err
+	}
+	return drawTransition(screen)
 }
 
 // Navigate attempts to construct a path within the terrain.
 func Navigate(from, to vec.I2) []vec.I2 {
-	limits := scene.View.Bounds().Translate(scene.World.Position().Mul(-1))
+	limits := scene.Camera.ViewRect()
 	path, err := vec.FindPath(obstacles, paths, from, to, limits)
 	if err != nil {
 		// Go near to the cursor position.
@@ -0,0 +1,70 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import "log"
+
+// hotReloadInterval is how often, in display frames, watched assets are
+// checked for changes.
+const hotReloadInterval = 30
+
+var watchedAssets []*Assets
+
+// WatchAssets makes the engine reload the assets when their files change, while
+// the game runs with config.Debug set. If the current level is one of them, it
+// is reloaded (recomputing obstacles) with the game state left as it is.
+func WatchAssets(a *Assets) {
+	watchedAssets = append(watchedAssets, a)
+}
+
+// hotReload checks watched assets for changes. Errors are logged rather than
+// returned, so a bad edit doesn't end the game; fixing the file tries again.
+func hotReload() {
+	if !config.Debug || displayFrame%hotReloadInterval != 0 {
+		return
+	}
+	for _, a := range watchedAssets {
+		changed, err := a.Reload()
+		if err != nil {
+			log.Printf("hot reload: %v", err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+		log.Printf("hot reload: reloaded %s", a.manifest)
+		if !a.hasLevel(level) {
+			continue
+		}
+		if err := loadLevel(level); err != nil {
+			log.Printf("hot reload: reloading level: %v", err)
+			continue
+		}
+		scene.sortFixedIfNeeded()
+	}
+}
+
+// hasLevel reports whether l is one of the assets' levels.
+func (a *Assets) hasLevel(l *Level) bool {
+	if l == nil {
+		return false
+	}
+	for _, al := range a.Levels {
+		if al == l {
+			return true
+		}
+	}
+	return false
+}
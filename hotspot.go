@@ -0,0 +1,164 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"log"
+
+	"github.com/DrJosh9000/vec"
+)
+
+// Interaction is a way the player can interact with a Hotspot.
+type Interaction int
+
+const (
+	InteractUse = Interaction(iota)
+	InteractLook
+	InteractTalk
+)
+
+var (
+	hotspots    []*Hotspot
+	interaction = InteractUse
+
+	// The hotspot the player is walking towards, and the trigger to fire on arrival.
+	pendingHotspot *Hotspot
+	pendingTrigger string
+)
+
+// Walker can optionally be implemented by a Unit, so that the engine can send it
+// somewhere (for example, into range of a Hotspot).
+type Walker interface {
+	// WalkPath asks the unit to follow the path (as produced by Navigate).
+	WalkPath(path []vec.I2)
+}
+
+// Hotspot is a clickable region of the world. Clicking it fires the trigger for
+// the current interaction (see SetInteraction). Triggers used by hotspots should
+// usually be OnDemand.
+//
+// The region is the Doodad's frame, if Doodad is set, otherwise the Sprite's
+// current frame, if Sprite is set, otherwise Rect (in world coordinates).
+type Hotspot struct {
+	Doodad *Doodad
+	Sprite *Sprite
+	Rect   vec.Rect
+
+	// Triggers maps each supported interaction to the name of the trigger to fire.
+	Triggers map[Interaction]string
+
+	// If Range is positive, the player must be within Range pixels of the region
+	// before the trigger fires. If the player is further away, and implements
+	// Walker, they walk into range first.
+	Range int
+}
+
+// AddHotspot makes a hotspot clickable.
+func AddHotspot(h *Hotspot) { hotspots = append(hotspots, h) }
+
+// RemoveHotspot makes a hotspot no longer clickable.
+func RemoveHotspot(h *Hotspot) {
+	for i, g := range hotspots {
+		if g == h {
+			hotspots = append(hotspots[:i], hotspots[i+1:]...)
+			break
+		}
+	}
+	if pendingHotspot == h {
+		pendingHotspot = nil
+	}
+}
+
+// SetInteraction sets the interaction used when hotspots are clicked.
+func SetInteraction(i Interaction) { interaction = i }
+
+// Region returns the clickable region of the hotspot in world coordinates.
+func (h *Hotspot) Region() vec.Rect {
+	switch {
+	case h.Doodad != nil:
+		return vec.NewRect(h.Doodad.Dst())
+	case h.Sprite != nil:
+		return canonRect(drawPosition{h.Sprite}.Dst()).Translate(scene.World.Position().Mul(-1))
+	}
+	return h.Rect
+}
+
+// inRange reports whether p is within the hotspot's range.
+func (h *Hotspot) inRange(p vec.I2) bool {
+	if h.Range <= 0 {
+		return true
+	}
+	d := p.Sub(h.nearest(p))
+	return d.Dot(d) <= h.Range*h.Range
+}
+
+// nearest returns the point in the region nearest to p.
+func (h *Hotspot) nearest(p vec.I2) vec.I2 {
+	r := h.Region()
+	return p.ClampLo(r.UL).ClampHi(r.DR.Sub(vec.I2{1, 1}))
+}
+
+// handleHotspots handles clicks on hotspots, and fires the pending hotspot trigger
+// once the player has walked into range. Returns true if the event was consumed.
+func handleHotspots(e *Event) bool {
+	pos := playerSprite.Pos.I2()
+	if pendingHotspot != nil {
+		switch {
+		case pendingHotspot.inRange(pos):
+			player.GoIdle()
+			FireTrigger(pendingTrigger)
+			pendingHotspot = nil
+		case len(player.Path()) == 0:
+			// Stopped before getting in range; give up.
+			if config.Debug {
+				log.Printf("couldn't get in range of hotspot for %q", pendingTrigger)
+			}
+			pendingHotspot = nil
+		}
+	}
+	if e.Type != EventMouseUp {
+		return false
+	}
+	// Later hotspots are on top.
+	for i := len(hotspots) - 1; i >= 0; i-- {
+		h := hotspots[i]
+		if !h.Region().Contains(e.WorldPos) {
+			continue
+		}
+		name, ok := h.Triggers[interaction]
+		if !ok {
+			continue
+		}
+		pendingHotspot = nil
+		if h.inRange(pos) {
+			FireTrigger(name)
+			return true
+		}
+		w, ok := player.(Walker)
+		if !ok {
+			if config.Debug {
+				log.Printf("player %T is not a Walker; firing %q out of range", player, name)
+			}
+			FireTrigger(name)
+			return true
+		}
+		w.WalkPath(Navigate(pos, h.nearest(pos)))
+		pendingHotspot, pendingTrigger = h, name
+		return true
+	}
+	// Clicking elsewhere cancels walking to a hotspot.
+	pendingHotspot = nil
+	return false
+}
@@ -0,0 +1,163 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"testing"
+
+	"github.com/DrJosh9000/vec"
+)
+
+// testWalker is a Unit and Walker that records what it was asked to do.
+type testWalker struct {
+	path   []vec.I2
+	walked int
+	idled  int
+}
+
+func (w *testWalker) GoIdle()                    { w.idled++; w.path = nil }
+func (w *testWalker) Footprint() (ul, dr vec.I2) { return }
+func (w *testWalker) Path() []vec.I2             { return w.path }
+func (w *testWalker) WalkPath(path []vec.I2)     { w.walked++; w.path = path }
+
+func TestHotspotRegion(t *testing.T) {
+	defer func(s *Scene) { scene = s }(scene)
+	scene = NewScene(vec.I2{200, 100}, vec.I2{200, 100})
+
+	flipped := testSpeaker(100, 50)
+	flipped.Geom.FlipH = true
+	sheet := &Sheet{Key: "test", FrameSize: vec.I2{16, 24}, FrameInfos: make([]FrameInfo, 1)}
+	tests := []struct {
+		h    *Hotspot
+		want vec.Rect
+	}{
+		{&Hotspot{Rect: vec.NewRect(1, 2, 3, 4)}, vec.NewRect(1, 2, 3, 4)},
+		{
+			&Hotspot{
+				Doodad: &Doodad{
+					P:          vec.I2{100, 50},
+					BaseDoodad: &BaseDoodad{SheetFrame: &SheetFrame{Sheet: sheet}, Offset: vec.I2{8, 24}},
+				},
+				Rect: vec.NewRect(1, 2, 3, 4), // ignored
+			},
+			vec.NewRect(92, 26, 108, 50),
+		},
+		{&Hotspot{Sprite: testSpeaker(100, 50)}, vec.NewRect(95, 30, 105, 50)},
+		{&Hotspot{Sprite: flipped}, vec.NewRect(95, 30, 105, 50)},
+	}
+	for i, test := range tests {
+		got := test.h.Region()
+		if got != test.want {
+			t.Errorf("test %d: Region() = %v, want %v", i, got, test.want)
+		}
+		if c := got.UL.Add(got.DR).Div(2); !got.Contains(c) {
+			t.Errorf("test %d: Region() %v doesn't contain its centre %v", i, got, c)
+		}
+	}
+}
+
+func TestHotspotRange(t *testing.T) {
+	tests := []struct {
+		p           vec.I2
+		rng         int
+		nearest     vec.I2
+		wantInRange bool
+	}{
+		{vec.I2{15, 15}, 0, vec.I2{15, 15}, true}, // inside
+		{vec.I2{0, 0}, 0, vec.I2{10, 10}, true},   // any distance without a Range
+		{vec.I2{0, 0}, 5, vec.I2{10, 10}, false},
+		{vec.I2{5, 15}, 5, vec.I2{10, 15}, true}, // exactly Range away
+		{vec.I2{4, 15}, 5, vec.I2{10, 15}, false},
+		{vec.I2{30, 25}, 5, vec.I2{19, 19}, false},
+		{vec.I2{22, 22}, 5, vec.I2{19, 19}, true}, // diagonally, 3² + 3² <= 5²
+	}
+	for _, test := range tests {
+		h := &Hotspot{Rect: vec.NewRect(10, 10, 20, 20), Range: test.rng}
+		if got := h.nearest(test.p); got != test.nearest {
+			t.Errorf("nearest(%v) = %v, want %v", test.p, got, test.nearest)
+		}
+		if got := h.inRange(test.p); got != test.wantInRange {
+			t.Errorf("with Range %d: inRange(%v) = %t, want %t", test.rng, test.p, got, test.wantInRange)
+		}
+	}
+}
+
+func TestHandleHotspots(t *testing.T) {
+	defer func(c *Config, s *Scene, u Unit, ps *Sprite, o, p *vec.Graph) {
+		config, scene, player, playerSprite, obstacles, paths = c, s, u, ps, o, p
+		hotspots, pendingHotspot, triggersByName = nil, nil, nil
+	}(config, scene, player, playerSprite, obstacles, paths)
+
+	config = &Config{}
+	scene = NewScene(vec.I2{320, 240}, vec.I2{320, 240})
+	obstacles, paths = vec.NewGraph(), vec.NewGraph()
+	w := &testWalker{}
+	player, playerSprite = w, &Sprite{Pos: vec.F2{100, 100}}
+
+	fired := 0
+	triggersByName = map[string]*Trigger{
+		"open": {Name: "open", OnDemand: true, Repeat: true, Fire: func(int) { fired++ }},
+	}
+	h := &Hotspot{
+		Rect:     vec.NewRect(200, 100, 210, 110),
+		Triggers: map[Interaction]string{InteractUse: "open"},
+		Range:    10,
+	}
+	hotspots = []*Hotspot{h}
+	click := func(p vec.I2) bool { return handleHotspots(&Event{Type: EventMouseUp, WorldPos: p}) }
+	tick := func() { handleHotspots(&Event{}) }
+
+	// Out of range: walk there first.
+	if !click(vec.I2{205, 105}) {
+		t.Errorf("click on hotspot not consumed")
+	}
+	if fired != 0 || w.walked != 1 || pendingHotspot != h {
+		t.Fatalf("after click out of range: fired %d, walked %d, pending %v; want 0, 1, the hotspot", fired, w.walked, pendingHotspot)
+	}
+	w.path = []vec.I2{{195, 105}} // still walking
+	tick()
+	if fired != 0 {
+		t.Errorf("fired %d times while walking, want 0", fired)
+	}
+	playerSprite.Pos = vec.F2{192, 105}
+	tick()
+	if fired != 1 || w.idled != 1 || pendingHotspot != nil {
+		t.Errorf("after arriving: fired %d, idled %d, pending %v; want 1, 1, nil", fired, w.idled, pendingHotspot)
+	}
+
+	// In range: fire straight away.
+	if !click(vec.I2{200, 100}) || fired != 2 || w.walked != 1 {
+		t.Errorf("after click in range: fired %d, walked %d; want 2, 1", fired, w.walked)
+	}
+
+	// Stopping short gives up.
+	playerSprite.Pos = vec.F2{100, 100}
+	click(vec.I2{205, 105})
+	w.path = nil
+	tick()
+	if fired != 2 || pendingHotspot != nil {
+		t.Errorf("after stopping short: fired %d, pending %v; want 2, nil", fired, pendingHotspot)
+	}
+
+	// Clicking elsewhere cancels.
+	click(vec.I2{205, 105})
+	w.path = []vec.I2{{150, 100}}
+	if click(vec.I2{10, 10}) {
+		t.Errorf("click away from hotspots consumed")
+	}
+	if pendingHotspot != nil {
+		t.Errorf("after clicking elsewhere: pending %v, want nil", pendingHotspot)
+	}
+}
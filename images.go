@@ -16,11 +16,13 @@ package awakengine
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
+	"image"
 	"image/color"
+	"image/draw"
 	"image/png"
 	"log"
+	"sort"
 
 	"github.com/DrJosh9000/vec"
 	"github.com/hajimehoshi/ebiten"
@@ -29,71 +31,226 @@ import (
 var (
 	allData = make(map[string][]byte)
 
-	// Hey guess what? We're going to draw all the source images into one giant texture,
-	// then do a single epic draw call during the game. Wheeee!
-	composite       *ebiten.Image
-	compositeOffset = make(map[string]vec.I2)
-	compositeSize   = vec.I2{1024, 1024}
+	// Hey guess what? We're going to draw all the source images into a few giant
+	// textures, then do one epic draw call per texture during the game. Wheeee!
+	atlasPages    []*ebiten.Image
+	atlasPackers  []*maxRects // free space on each page
+	atlasPlaces   = make(map[string]atlasPlace)
+	atlasPageSize = vec.I2{1024, 1024}
+	atlasLoaded   bool                    // set by loadAllImages
+	atlasPending  = make(map[string]bool) // registered since, not yet placed
+	atlasFreed    int                     // area released since the last repack
+	atlasError    string                  // from the last updateAtlas, logged once
 
-	sizes = make(map[string]vec.I2)
+	imageRefs = make(map[string]int)
+	sizes     = make(map[string]vec.I2)
 )
 
-// RegisterImage tells the engine that a key maps to an image.
-// Registered images will be loaded into a texture atlas with loadAllImages.
+// RegisterImage tells the engine that a key maps to an image, and takes a
+// reference to it. Images registered before the game runs are loaded into the
+// texture atlas with loadAllImages; images registered later are added to the
+// atlas before the next frame is drawn. Registering a key again with different
+// data replaces the image.
 func RegisterImage(key string, png []byte) {
+	imageRefs[key]++
+	replaceImage(key, png)
+}
+
+// replaceImage sets the data for an image without taking a reference.
+func replaceImage(key string, png []byte) {
+	if old, ok := allData[key]; ok && bytes.Equal(old, png) {
+		return
+	}
 	allData[key] = png
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(png)); err == nil {
+		sizes[key] = vec.I2{cfg.Width, cfg.Height}
+	}
+	if atlasLoaded {
+		atlasPending[key] = true
+	}
 }
 
-func loadAllImages() error {
-	// Prerender terrain layers to a texture.
-	f, err := ebiten.NewImage(compositeSize.X, compositeSize.Y, ebiten.FilterNearest)
-	if err != nil {
-		return fmt.Errorf("creating composite texture: %v", err)
+// UnloadImage releases a reference to an image taken by RegisterImage. When
+// there are no references left, the image is forgotten and its space in the
+// atlas can be reused. Parts using the image must be retired first.
+func UnloadImage(key string) {
+	if imageRefs[key]--; imageRefs[key] > 0 {
+		return
 	}
-	if err := f.Fill(color.Transparent); err != nil {
-		return fmt.Errorf("filling composite texture with transparent color: %v", err)
+	delete(imageRefs, key)
+	delete(allData, key)
+	delete(atlasPending, key)
+	if pl, ok := atlasPlaces[key]; ok {
+		atlasPackers[pl.page].release(pl.rect())
+		atlasFreed += pl.size.X * pl.size.Y
+		delete(atlasPlaces, key)
 	}
-	composite = f
-	p := vec.I2{0, 0}
-	my := 0
+	delete(sizes, key)
+}
+
+func loadAllImages() error {
+	atlasLoaded = true
+	return DefragAtlas()
+}
+
+// DefragAtlas packs all the images into the atlas afresh, which reclaims space
+// fragmented by UnloadImage and may need fewer pages. It happens automatically
+// when an image registered at runtime doesn't otherwise fit, but games may call
+// it at a convenient time, such as between levels.
+func DefragAtlas() error {
+	imgs := make(map[string]image.Image, len(allData))
 	for k, d := range allData {
-		i, err := loadPNG(d, ebiten.FilterNearest)
+		i, err := png.Decode(bytes.NewReader(d))
 		if err != nil {
+			return fmt.Errorf("decoding image %q: %v", k, err)
+		}
+		imgs[k] = i
+		sizes[k] = vec.I2{i.Bounds().Dx(), i.Bounds().Dy()}
+	}
+	places, packers, err := packAtlas(sizes, atlasPageSize)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range atlasPages {
+		p.Dispose()
+	}
+	atlasPages = nil
+	atlasPackers = nil
+	for range packers {
+		if err := addAtlasPage(); err != nil {
+			return err
+		}
+	}
+	atlasPlaces, atlasPackers = places, packers
+	atlasPending = make(map[string]bool)
+	atlasFreed = 0
+
+	var dump []*image.NRGBA
+	if config.AtlasDump != "" {
+		dump = make([]*image.NRGBA, len(atlasPages))
+		for i := range dump {
+			dump[i] = image.NewNRGBA(image.Rect(0, 0, atlasPageSize.X, atlasPageSize.Y))
+		}
+	}
+	for k, img := range imgs {
+		if err := drawToAtlas(k, img); err != nil {
 			return err
 		}
-		w, h := i.Size()
-		sizes[k] = vec.I2{w, h}
-		if w >= compositeSize.X {
-			return fmt.Errorf("source image %q too wide [%d >= %d]", k, w, compositeSize.X)
+		if dump != nil {
+			pl := places[k]
+			r := image.Rectangle{image.Pt(pl.pos.C()), image.Pt(pl.pos.Add(pl.size).C())}
+			draw.Draw(dump[pl.page], r, img, img.Bounds().Min, draw.Src)
 		}
-		if p.X+w >= compositeSize.X {
-			p = vec.I2{0, my}
+	}
+	if dump != nil {
+		if err := dumpAtlas(config.AtlasDump, dump); err != nil {
+			return fmt.Errorf("dumping atlas: %v", err)
 		}
-		y := p.Y + h
-		if y >= compositeSize.Y {
-			return errors.New("too much source image (TODO josh: fix)")
+	}
+	return nil
+}
+
+// updateAtlas places images registered since the atlas was loaded. If one
+// doesn't fit anywhere, the atlas is defragmented if that might help, or else
+// a page is added. Images stay pending until they are placed, so an image that
+// fails to decode doesn't lose the others.
+func updateAtlas() error {
+	if len(atlasPending) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(atlasPending))
+	for k := range atlasPending {
+		keys = append(keys, k)
+	}
+	sort.Sort(bySize{keys, sizes})
+
+	for _, k := range keys {
+		img, err := png.Decode(bytes.NewReader(allData[k]))
+		if err != nil {
+			return fmt.Errorf("decoding image %q: %v", k, err)
+		}
+		sz := vec.I2{img.Bounds().Dx(), img.Bounds().Dy()}
+		sizes[k] = sz
+		if sz.X > atlasPageSize.X || sz.Y > atlasPageSize.Y {
+			return fmt.Errorf("image %q (%dx%d) is larger than an atlas page (%dx%d)", k, sz.X, sz.Y, atlasPageSize.X, atlasPageSize.Y)
 		}
-		if y > my {
-			my = y
+		if !placeImage(k, sz) {
+			if config.Debug {
+				log.Printf("atlas full placing %q; defragmenting", k)
+			}
+			return DefragAtlas()
 		}
-		compositeOffset[k] = p
-		if config.Debug {
-			log.Printf("placing %q at (%d, %d)-(%d, %d)", k, p.X, p.Y, p.X+w, p.Y+h)
+		for len(atlasPages) < len(atlasPackers) {
+			if err := addAtlasPage(); err != nil {
+				return err
+			}
 		}
-		if err := f.DrawImage(i, &ebiten.DrawImageOptions{ImageParts: &wholeImageAt{p, vec.I2{w, h}}}); err != nil {
+		if err := drawToAtlas(k, img); err != nil {
 			return err
 		}
-		p.X += w
+		delete(atlasPending, k)
 	}
 	return nil
 }
 
-func loadPNG(img []byte, filter ebiten.Filter) (*ebiten.Image, error) {
-	i, err := png.Decode(bytes.NewReader(img))
+// placeImage finds space in the atlas for an image, which may reuse the space
+// it had before (if it is being replaced). If it doesn't fit on any page, a new
+// page is packed, unless defragmenting might make room, when placeImage returns
+// false and leaves the image where it was.
+func placeImage(key string, sz vec.I2) bool {
+	old, had := atlasPlaces[key]
+	if had {
+		atlasPackers[old.page].release(old.rect())
+	}
+	for i, m := range atlasPackers {
+		if p, ok := m.insert(sz); ok {
+			atlasPlaces[key] = atlasPlace{i, p, sz}
+			return true
+		}
+	}
+	if atlasFreed > 0 {
+		if had {
+			atlasPackers[old.page].place(old.rect())
+		}
+		return false
+	}
+	m := newMaxRects(atlasPageSize)
+	atlasPackers = append(atlasPackers, m)
+	p, _ := m.insert(sz)
+	atlasPlaces[key] = atlasPlace{len(atlasPackers) - 1, p, sz}
+	return true
+}
+
+// addAtlasPage adds an empty page texture.
+func addAtlasPage() error {
+	f, err := ebiten.NewImage(atlasPageSize.X, atlasPageSize.Y, ebiten.FilterNearest)
+	if err != nil {
+		return fmt.Errorf("creating atlas page texture: %v", err)
+	}
+	if err := f.Fill(color.Transparent); err != nil {
+		return fmt.Errorf("filling atlas page texture with transparent color: %v", err)
+	}
+	atlasPages = append(atlasPages, f)
+	return nil
+}
+
+// drawToAtlas draws an image into its place in the atlas.
+func drawToAtlas(key string, img image.Image) error {
+	pl := atlasPlaces[key]
+	sz := pl.size
+	if config.Debug {
+		log.Printf("placing %q on page %d at (%d, %d)-(%d, %d)", key, pl.page, pl.pos.X, pl.pos.Y, pl.pos.X+sz.X, pl.pos.Y+sz.Y)
+	}
+	i, err := ebiten.NewImageFromImage(img, ebiten.FilterNearest)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return ebiten.NewImageFromImage(i, filter)
+	// Copy, rather than blend, in case the space held another image before.
+	return atlasPages[pl.page].DrawImage(i, &ebiten.DrawImageOptions{
+		ImageParts:    &wholeImageAt{pl.pos, sz},
+		CompositeMode: ebiten.CompositeModeCopy,
+	})
 }
 
 type wholeImageAt struct {
@@ -0,0 +1,249 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	catalogs = make(map[string]Catalog)
+
+	// FallbackLocale is the locale used for messages missing from the current locale.
+	FallbackLocale = "en"
+
+	// locale is the current locale set by SetLocale; until then (while it is
+	// empty), FallbackLocale is current.
+	locale string
+
+	// pluralRules choose plural forms per locale. Locales without a rule use
+	// defaultPluralRule.
+	pluralRules = make(map[string]func(n int) string)
+
+	// missingMsgs records message IDs looked up but missing, by locale.
+	missingMsgs = make(map[string]map[string]bool)
+
+	// localizedTexts are laid out Texts with a Msg, and the locale each was laid
+	// out in, to re-lay out if the locale changes or the catalogs are reloaded.
+	localizedTexts = make(map[*Text]string)
+)
+
+// Catalog maps message IDs to messages for one locale.
+type Catalog map[string]*Message
+
+// Message is one translated message, with plural forms. In catalog files, a
+// message can either be a plain string, or an object with plural forms:
+//
+//	{
+//	  "greeting": "Hello, {name}!",
+//	  "apples": {"one": "{n} apple", "other": "{n} apples"}
+//	}
+//
+// The plural forms used depend on the locale's plural rule (see
+// RegisterPluralRule); the default rule uses "zero" (if present), "one", and
+// "other".
+type Message struct {
+	Forms map[string]string
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (m *Message) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		m.Forms = map[string]string{"other": s}
+		return nil
+	}
+	if err := json.Unmarshal(b, &m.Forms); err != nil {
+		return fmt.Errorf("message must be a string or an object of plural forms: %v", err)
+	}
+	if _, ok := m.Forms["other"]; !ok {
+		return fmt.Errorf("message with plural forms has no \"other\" form")
+	}
+	return nil
+}
+
+// Msg refers to a localized message by ID, and provides values for it.
+// Placeholders of the form {name} in the message are replaced with Args["name"],
+// and {n} is replaced with N. N also selects the plural form.
+type Msg struct {
+	ID   string
+	N    int
+	Args map[string]string
+}
+
+// String translates the message into the current locale. Messages missing from
+// the current locale are taken from FallbackLocale, and failing that, the ID is used.
+func (m *Msg) String() string {
+	loc := Locale()
+	msg := lookupMsg(loc, m.ID)
+	if msg == nil && loc != FallbackLocale {
+		// Not lookupMsg: only misses from the current locale are recorded.
+		loc = FallbackLocale
+		msg = catalogs[loc][m.ID]
+	}
+	if msg == nil {
+		return m.ID
+	}
+	rule := pluralRules[loc]
+	if rule == nil {
+		rule = defaultPluralRule
+	}
+	s, ok := msg.Forms[rule(m.N)]
+	if !ok {
+		s = msg.Forms["other"]
+	}
+	return substitute(s, m.N, m.Args)
+}
+
+func lookupMsg(loc, id string) *Message {
+	if m := catalogs[loc][id]; m != nil {
+		return m
+	}
+	if missingMsgs[loc] == nil {
+		missingMsgs[loc] = make(map[string]bool)
+	}
+	missingMsgs[loc][id] = true
+	return nil
+}
+
+func defaultPluralRule(n int) string {
+	switch n {
+	case 0:
+		return "zero"
+	case 1:
+		return "one"
+	}
+	return "other"
+}
+
+// RegisterPluralRule sets the function choosing plural forms for a locale.
+func RegisterPluralRule(loc string, rule func(n int) string) {
+	pluralRules[loc] = rule
+}
+
+// substitute replaces {n} and {name} placeholders.
+func substitute(s string, n int, args map[string]string) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "{")
+		if i < 0 {
+			break
+		}
+		j := strings.Index(s[i:], "}")
+		if j < 0 {
+			break
+		}
+		j += i
+		b.WriteString(s[:i])
+		name := s[i+1 : j]
+		if v, ok := args[name]; ok {
+			b.WriteString(v)
+		} else if name == "n" {
+			b.WriteString(strconv.Itoa(n))
+		} else {
+			b.WriteString(s[i : j+1])
+		}
+		s = s[j+1:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// LoadCatalog reads a JSON catalog of messages for a locale, adding to (and
+// overriding) any messages already loaded for it.
+func LoadCatalog(loc string, r io.Reader) error {
+	var c Catalog
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return fmt.Errorf("decoding %s catalog: %v", loc, err)
+	}
+	if catalogs[loc] == nil {
+		catalogs[loc] = make(Catalog, len(c))
+	}
+	for id, m := range c {
+		catalogs[loc][id] = m
+		delete(missingMsgs[loc], id)
+	}
+	// The messages may have changed, so lay out all the Texts again.
+	for t := range localizedTexts {
+		localizedTexts[t] = ""
+	}
+	return nil
+}
+
+// updateLocalizedTexts forgets retired Texts, so they can be garbage collected,
+// and lays out again those laid out in another locale or before a catalog was
+// loaded.
+func updateLocalizedTexts() {
+	loc := Locale()
+	for t, l := range localizedTexts {
+		switch {
+		case t.Retire():
+			delete(localizedTexts, t)
+		case l != loc:
+			t.relocalize()
+		}
+	}
+}
+
+// Locale returns the current locale.
+func Locale() string {
+	if locale == "" {
+		return FallbackLocale
+	}
+	return locale
+}
+
+// SetLocale changes the current locale, and lays out again all the text that
+// uses a Msg.
+func SetLocale(loc string) {
+	locale = loc
+	updateLocalizedTexts()
+}
+
+// UntranslatedIDs returns the sorted IDs of messages that are missing from a
+// locale's catalog, but are either in another locale's catalog or have been
+// looked up while the game was running.
+func UntranslatedIDs(loc string) []string {
+	ids := make(map[string]bool)
+	for l, c := range catalogs {
+		if l == loc {
+			continue
+		}
+		for id := range c {
+			ids[id] = true
+		}
+	}
+	for _, m := range missingMsgs {
+		for id := range m {
+			ids[id] = true
+		}
+	}
+	var out []string
+	for id := range ids {
+		if catalogs[loc][id] == nil {
+			out = append(out, id)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,137 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DrJosh9000/vec"
+)
+
+func TestMsg(t *testing.T) {
+	defer func() { locale = "" }()
+	if got, want := Locale(), FallbackLocale; got != want {
+		t.Errorf("Locale() before SetLocale = %q, want %q", got, want)
+	}
+	catalogs = make(map[string]Catalog)
+	missingMsgs = make(map[string]map[string]bool)
+	if err := LoadCatalog("en", strings.NewReader(`{
+		"greeting": "Hello, {name}!",
+		"apples": {"zero": "no apples", "one": "{n} apple", "other": "{n} apples"},
+		"bye": "Bye"
+	}`)); err != nil {
+		t.Fatalf("LoadCatalog(en): %v", err)
+	}
+	if err := LoadCatalog("fr", strings.NewReader(`{
+		"greeting": "Bonjour, {name} !",
+		"apples": {"one": "{n} pomme", "other": "{n} pommes"}
+	}`)); err != nil {
+		t.Fatalf("LoadCatalog(fr): %v", err)
+	}
+	RegisterPluralRule("fr", func(n int) string {
+		if n <= 1 {
+			return "one"
+		}
+		return "other"
+	})
+
+	tests := []struct {
+		locale string
+		msg    Msg
+		want   string
+	}{
+		{"en", Msg{ID: "greeting", Args: map[string]string{"name": "Bob"}}, "Hello, Bob!"},
+		{"en", Msg{ID: "apples", N: 0}, "no apples"},
+		{"en", Msg{ID: "apples", N: 1}, "1 apple"},
+		{"en", Msg{ID: "apples", N: 3}, "3 apples"},
+		{"fr", Msg{ID: "greeting", Args: map[string]string{"name": "Bob"}}, "Bonjour, Bob !"},
+		{"fr", Msg{ID: "apples", N: 0}, "0 pomme"},
+		{"fr", Msg{ID: "bye"}, "Bye"},                 // fallback to en
+		{"fr", Msg{ID: "nope"}, "nope"},               // missing everywhere
+		{"en", Msg{ID: "greeting"}, "Hello, {name}!"}, // missing args are left alone
+	}
+	for _, test := range tests {
+		SetLocale(test.locale)
+		if got := test.msg.String(); got != test.want {
+			t.Errorf("In %s, %+v.String() = %q, want %q", test.locale, test.msg, got, test.want)
+		}
+	}
+
+	if got, want := UntranslatedIDs("fr"), []string{"bye", "nope"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("UntranslatedIDs(fr) = %v, want %v", got, want)
+	}
+	if missingMsgs["en"]["nope"] {
+		t.Errorf("falling back to en recorded nope as missing from en")
+	}
+}
+
+func TestUpdateLocalizedTexts(t *testing.T) {
+	defer func(s *Scene, c map[string]Catalog) {
+		scene, catalogs, locale = s, c, ""
+		localizedTexts = make(map[*Text]string)
+	}(scene, catalogs)
+	scene = NewScene(vec.I2{200, 100}, vec.I2{200, 100})
+	catalogs = map[string]Catalog{"en": {"hi": {Forms: map[string]string{"other": "hi"}}}}
+	localizedTexts = make(map[*Text]string)
+
+	font := newTestFont("abcdefghijklmnopqrstuvwxyz ")
+	parent := &View{}
+	kept := &Text{View: &View{}, Font: font, Msg: &Msg{ID: "hi"}}
+	gone := &Text{View: &View{}, Font: font, Msg: &Msg{ID: "hi"}}
+	gone.SetParent(parent)
+	kept.Layout(true)
+	gone.Layout(true)
+
+	parent.Dispose()
+	updateLocalizedTexts()
+	if got, want := localizedTexts, map[*Text]string{kept: "en"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after disposing a Text's parent, localizedTexts = %v, want %v", got, want)
+	}
+
+	// Loading a catalog for the current locale lays the text out again.
+	if err := LoadCatalog("en", strings.NewReader(`{"hi": "hello"}`)); err != nil {
+		t.Fatalf("LoadCatalog(en): %v", err)
+	}
+	updateLocalizedTexts()
+	if got, want := kept.Text, "hello"; got != want {
+		t.Errorf("after reloading the catalog, Text = %q, want %q", got, want)
+	}
+
+	// So does changing the locale.
+	if err := LoadCatalog("fr", strings.NewReader(`{"hi": "salut"}`)); err != nil {
+		t.Fatalf("LoadCatalog(fr): %v", err)
+	}
+	SetLocale("fr")
+	if got, want := kept.Text, "salut"; got != want {
+		t.Errorf("after SetLocale(fr), Text = %q, want %q", got, want)
+	}
+	if got, want := localizedTexts[kept], "fr"; got != want {
+		t.Errorf("after SetLocale(fr), Text laid out in %q, want %q", got, want)
+	}
+}
+
+func TestLoadCatalogErrors(t *testing.T) {
+	for _, src := range []string{
+		`{"a": 3}`,
+		`{"a": {"one": "x"}}`,
+		`["a"]`,
+	} {
+		if err := LoadCatalog("xx", strings.NewReader(src)); err == nil {
+			t.Errorf("LoadCatalog(%s) error = nil, want error", src)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// icons are sheet frames that can be embedded in Text with [icon=name].
+var icons = make(map[string]*SheetFrame)
+
+// RegisterIcon makes a sheet frame available to Text markup as [icon=name].
+func RegisterIcon(name string, f *SheetFrame) {
+	icons[name] = f
+}
+
+// textStyle is the style of a run of text.
+type textStyle struct {
+	colour      color.Color // nil means untinted
+	bold        bool
+	wave, shake bool
+}
+
+// markupItem is a character (or icon) in marked-up text.
+type markupItem struct {
+	c     rune
+	style textStyle
+	icon  *SheetFrame
+	pause int // extra Advance calls to wait before revealing this item
+}
+
+// parseMarkup parses Text markup into items. The tags are:
+//
+//	[color=#rrggbb]...[/color]  colour (also #rgb, #rrggbbaa)
+//	[b]...[/b]                  bold (uses Text.BoldFont)
+//	[wave]...[/wave]            wavy text
+//	[shake]...[/shake]          shaky text
+//	[icon=name]                 an icon (see RegisterIcon)
+//	[pause=n]                   pause the typewriter reveal for n steps
+//
+// [[ is a literal [. Tags may nest; unclosed tags end at the end of the text.
+// Anything that isn't a recognised tag is left as literal text.
+func parseMarkup(s string) []markupItem {
+	var (
+		items   []markupItem
+		colours []color.Color
+		bold    int
+		wave    int
+		shake   int
+		pause   int
+	)
+	style := func() textStyle {
+		st := textStyle{bold: bold > 0, wave: wave > 0, shake: shake > 0}
+		if len(colours) > 0 {
+			st.colour = colours[len(colours)-1]
+		}
+		return st
+	}
+	emit := func(c rune, icon *SheetFrame) {
+		items = append(items, markupItem{c: c, style: style(), icon: icon, pause: pause})
+		pause = 0
+	}
+	for len(s) > 0 {
+		if strings.HasPrefix(s, "[[") {
+			emit('[', nil)
+			s = s[2:]
+			continue
+		}
+		if s[0] == '[' {
+			if end := strings.Index(s, "]"); end > 0 {
+				tag := s[1:end]
+				name, arg := tag, ""
+				if i := strings.Index(tag, "="); i >= 0 {
+					name, arg = tag[:i], tag[i+1:]
+				}
+				ok := true
+				switch name {
+				case "color":
+					c, err := parseColour(arg)
+					if err != nil {
+						ok = false
+						break
+					}
+					colours = append(colours, c)
+				case "/color":
+					if ok = len(colours) > 0; ok {
+						colours = colours[:len(colours)-1]
+					}
+				case "b":
+					bold++
+				case "/b":
+					if ok = bold > 0; ok {
+						bold--
+					}
+				case "wave":
+					wave++
+				case "/wave":
+					if ok = wave > 0; ok {
+						wave--
+					}
+				case "shake":
+					shake++
+				case "/shake":
+					if ok = shake > 0; ok {
+						shake--
+					}
+				case "icon":
+					f := icons[arg]
+					if ok = f != nil; ok {
+						emit(0, f)
+					}
+				case "pause":
+					n, err := strconv.Atoi(arg)
+					if ok = err == nil && n >= 0; ok {
+						pause += n
+					}
+				default:
+					ok = false
+				}
+				if ok {
+					s = s[end+1:]
+					continue
+				}
+			}
+		}
+		// Literal text up to the next [.
+		lit := s
+		if i := strings.Index(s[1:], "["); i >= 0 {
+			lit = s[:i+1]
+		}
+		for _, c := range lit {
+			emit(c, nil)
+		}
+		s = s[len(lit):]
+	}
+	return items
+}
+
+// parseColour parses #rgb, #rrggbb, or #rrggbbaa.
+func parseColour(s string) (color.Color, error) {
+	if !strings.HasPrefix(s, "#") {
+		return nil, strconv.ErrSyntax
+	}
+	s = s[1:]
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) == 6 {
+		s += "ff"
+	}
+	if len(s) != 8 {
+		return nil, strconv.ErrSyntax
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return nil, err
+	}
+	return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
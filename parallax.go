@@ -0,0 +1,133 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"math"
+
+	"github.com/DrJosh9000/vec"
+)
+
+// ParallaxLayer is an image behind or in front of the World that scrolls at a
+// fraction of the camera movement, and can repeat to fill the screen. Its Z
+// orders it against the World (at 0) and its contents: use a negative Z for a
+// background, or a large one (under the HUD's 100000) for a foreground.
+type ParallaxLayer struct {
+	*View
+	Key    string // image key
+	Factor vec.F2 // fraction of camera movement; 0 is fixed to the screen, 1 moves with the World
+	Offset vec.I2 // where the image is when the camera is at the World origin
+	TileX  bool   // repeat horizontally forever
+	TileY  bool   // repeat vertically forever
+
+	tiles []*layerTile
+}
+
+// layerTile is one repeat of a layer's image.
+type layerTile struct {
+	layer *ParallaxLayer
+	at    vec.I2 // which repeat
+	gone  bool
+}
+
+func (t *layerTile) Container() *View { return t.layer.View }
+func (t *layerTile) Fixed() bool      { return true }
+func (t *layerTile) ImageKey() string { return t.layer.Key }
+func (t *layerTile) Retire() bool     { return t.gone || t.layer.Retire() }
+func (t *layerTile) Visible() bool    { return t.layer.Visible() }
+func (t *layerTile) Z() int           { return t.layer.Z() }
+
+func (t *layerTile) Src() (x0, y0, x1, y1 int) {
+	x1, y1 = sizes[t.layer.Key].C()
+	return
+}
+
+func (t *layerTile) Dst() (x0, y0, x1, y1 int) {
+	sz := sizes[t.layer.Key]
+	ul := t.at.EMul(sz)
+	return ul.X, ul.Y, ul.X + sz.X, ul.Y + sz.Y
+}
+
+// AddLayer adds a parallax layer to the scene, with the given Z.
+func (s *Scene) AddLayer(l *ParallaxLayer, z int) {
+	if l.View == nil {
+		l.View = &View{}
+	}
+	l.SetParent(s.layers)
+	l.SetZ(z)
+	s.layerList = append(s.layerList, l)
+	s.updateLayer(l)
+}
+
+// updateLayers scrolls the layers to follow the camera, dropping any that
+// were disposed of.
+func (s *Scene) updateLayers() {
+	ls := s.layerList[:0]
+	for _, l := range s.layerList {
+		if l.Retire() {
+			continue
+		}
+		s.updateLayer(l)
+		ls = append(ls, l)
+	}
+	s.layerList = ls
+}
+
+// updateLayer positions the layer, and makes enough tiles to cover the view.
+func (s *Scene) updateLayer(l *ParallaxLayer) {
+	sz := sizes[l.Key]
+	if sz.X <= 0 || sz.Y <= 0 {
+		return
+	}
+	cam := s.World.RelativeBounds().UL.Mul(-1).F2()
+	pos := l.Offset.Sub(vec.I2{
+		int(math.Floor(cam.X*l.Factor.X + 0.5)),
+		int(math.Floor(cam.Y*l.Factor.Y + 0.5)),
+	})
+	view := s.Camera.ViewSize()
+	n := vec.I2{1, 1}
+	if l.TileX {
+		pos.X = -mod(-pos.X, sz.X)
+		n.X = view.X/sz.X + 2
+	}
+	if l.TileY {
+		pos.Y = -mod(-pos.Y, sz.Y)
+		n.Y = view.Y/sz.Y + 2
+	}
+	l.SetPositionAndSize(pos, n.EMul(sz))
+
+	if len(l.tiles) == n.X*n.Y {
+		return
+	}
+	for _, t := range l.tiles {
+		t.gone = true
+	}
+	l.tiles = l.tiles[:0]
+	for y := 0; y < n.Y; y++ {
+		for x := 0; x < n.X; x++ {
+			t := &layerTile{layer: l, at: vec.I2{x, y}}
+			l.tiles = append(l.tiles, t)
+			s.AddPart(t)
+		}
+	}
+}
+
+// mod is a modulo that is never negative, for positive m.
+func mod(a, m int) int {
+	if a %= m; a < 0 {
+		a += m
+	}
+	return a
+}
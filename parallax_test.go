@@ -0,0 +1,74 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"testing"
+
+	"github.com/DrJosh9000/vec"
+)
+
+func TestParallaxLayer(t *testing.T) {
+	sizes["test-bg"] = vec.I2{40, 30}
+	defer delete(sizes, "test-bg")
+
+	s := NewScene(vec.I2{100, 80}, vec.I2{400, 300})
+	tiled := &ParallaxLayer{Key: "test-bg", Factor: vec.F2{0.5, 0.5}, TileX: true}
+	fixed := &ParallaxLayer{Key: "test-bg", Offset: vec.I2{10, 20}}
+	s.AddLayer(tiled, -10)
+	s.AddLayer(fixed, 50000)
+
+	if got, want := len(tiled.tiles), 4; got != want { // 100/40 + 2 across, 1 down
+		t.Errorf("len(tiled.tiles) = %d, want %d", got, want)
+	}
+	if got, want := tiled.Z(), -10; got != want {
+		t.Errorf("tiled.Z() = %d, want %d", got, want)
+	}
+
+	tests := []struct {
+		look             vec.I2
+		tiled, fixedWant vec.I2 // layer positions
+	}{
+		{vec.I2{50, 40}, vec.I2{0, 0}, vec.I2{10, 20}},
+		{vec.I2{150, 140}, vec.I2{-10, -50}, vec.I2{10, 20}},
+		{vec.I2{250, 140}, vec.I2{-20, -50}, vec.I2{10, 20}},
+	}
+	for _, test := range tests {
+		s.Camera.LookAt(test.look)
+		s.Update()
+		if got := tiled.Position(); got != test.tiled {
+			t.Errorf("camera at %v: tiled.Position() = %v, want %v", test.look, got, test.tiled)
+		}
+		if got := fixed.Position(); got != test.fixedWant {
+			t.Errorf("camera at %v: fixed.Position() = %v, want %v", test.look, got, test.fixedWant)
+		}
+	}
+
+	if got, want := vec.NewRect(tiled.tiles[2].Dst()), vec.NewRect(80, 0, 120, 30); got != want {
+		t.Errorf("tiled.tiles[2].Dst() = %v, want %v", got, want)
+	}
+
+	tiled.Dispose()
+	s.Update()
+	if got, want := len(s.layerList), 1; got != want {
+		t.Errorf("after Dispose: len(layerList) = %d, want %d", got, want)
+	}
+	for _, p := range s.fixed {
+		if p.Part.(*layerTile).layer == tiled {
+			t.Errorf("tile of disposed layer still in scene")
+			break
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/DrJosh9000/vec"
+)
+
+// GameStater can optionally be implemented by a Game to have its own state
+// included by SaveGame and restored by LoadGame.
+type GameStater interface {
+	SaveState() ([]byte, error)
+	LoadState([]byte) error
+}
+
+// saveState is the engine state written by SaveGame.
+type saveState struct {
+	ModelFrame int                     `json:"model_frame"`
+	Level      string                  `json:"level,omitempty"`
+	PlayerPos  vec.F2                  `json:"player_pos"`
+	Triggers   map[string]triggerState `json:"triggers,omitempty"`
+	Vars       map[string]string       `json:"vars,omitempty"`
+	Transcript []TranscriptEntry       `json:"transcript,omitempty"`
+	Game       json.RawMessage         `json:"game,omitempty"`
+}
+
+type triggerState struct {
+	Fired   bool `json:"fired,omitempty"`
+	FiredAt int  `json:"fired_at,omitempty"`
+}
+
+// SaveGame writes the engine state (game time, level, player position, trigger
+// and timer state, game variables, and the dialogue transcript) as JSON, plus
+// the game's own state if it implements GameStater.
+func SaveGame(w io.Writer) error {
+	s := &saveState{
+		ModelFrame: modelFrame,
+		Level:      levelName,
+		Triggers:   make(map[string]triggerState, len(triggersByName)),
+		Vars:       vars,
+		Transcript: transcript,
+	}
+	if playerSprite != nil {
+		s.PlayerPos = playerSprite.Pos
+	}
+	for n, t := range triggersByName {
+		if t.fired {
+			s.Triggers[n] = triggerState{Fired: t.fired, FiredAt: t.firedAt}
+		}
+	}
+	if gs, ok := game.(GameStater); ok {
+		d, err := gs.SaveState()
+		if err != nil {
+			return fmt.Errorf("saving game state: %v", err)
+		}
+		s.Game = d
+	}
+	return json.NewEncoder(w).Encode(s)
+}
+
+// LoadGame restores state written by SaveGame. Any dialogue being shown is
+// discarded.
+func LoadGame(r io.Reader) error {
+	s := new(saveState)
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return fmt.Errorf("decoding saved game: %v", err)
+	}
+	if s.Level != levelName {
+		if err := restoreLevel(s.Level); err != nil {
+			return err
+		}
+	}
+	modelFrame = s.ModelFrame
+	for n, t := range triggersByName {
+		ts := s.Triggers[n]
+		t.fired, t.firedAt = ts.Fired, ts.FiredAt
+	}
+	if config.Debug {
+		for n := range s.Triggers {
+			if triggersByName[n] == nil {
+				log.Printf("saved game has state for unknown trigger %q", n)
+			}
+		}
+	}
+	vars = s.Vars
+	if vars == nil {
+		vars = make(map[string]string)
+	}
+	if playerSprite != nil {
+		playerSprite.Pos = s.PlayerPos
+		player.GoIdle()
+	}
+	transcript = s.Transcript
+	HideBacklog()
+	dialogueStack = nil
+	playNextDialogue()
+	if gs, ok := game.(GameStater); ok && s.Game != nil {
+		if err := gs.LoadState(s.Game); err != nil {
+			return fmt.Errorf("loading game state: %v", err)
+		}
+	}
+	return nil
+}
+
+// restoreLevel switches to the named level, where "" is the game's base level.
+func restoreLevel(name string) error {
+	if name != "" {
+		return ChangeLevel(name)
+	}
+	l, err := game.Level()
+	if err != nil {
+		return fmt.Errorf("loading level: %v", err)
+	}
+	if err := loadLevel(l); err != nil {
+		return err
+	}
+	levelName = ""
+	lastPlayerTile = vec.I2{-1, -1}
+	scene.sortFixedIfNeeded()
+	return nil
+}
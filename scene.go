@@ -23,9 +23,15 @@ import (
 //
 // Using a Scene as a parent will subtract the camera position. To use screen coordinates,
 // use a parent of nil.
+//
+// Parallax layers (see AddLayer) are drawn behind or in front of the World.
 type Scene struct {
 	*View
 	World, HUD *View
+	Camera     *Camera
+
+	layers    *View // parent of the parallax layers
+	layerList []*ParallaxLayer
 
 	fixed       drawList
 	fixedSorted bool
@@ -40,6 +46,8 @@ func NewScene(camSize, terrainSize vec.I2) *Scene {
 		View:  &View{},
 		World: &View{},
 		HUD:   &View{},
+
+		layers: &View{},
 	}
 	s.View.SetSize(camSize)
 	s.World.SetSize(terrainSize)
@@ -47,6 +55,9 @@ func NewScene(camSize, terrainSize vec.I2) *Scene {
 	s.HUD.SetSize(camSize)
 	s.HUD.SetParent(s.View)
 	s.HUD.SetZ(100000) // HUD over World, always
+	s.layers.SetSize(camSize)
+	s.layers.SetParent(s.View)
+	s.Camera = newCamera(s)
 	return s
 }
 
@@ -75,17 +86,24 @@ func (s *Scene) sortFixedIfNeeded() {
 }
 
 // CameraFocus sets the World offset such that p should be center of screen, or at least
-// within the bounds of the terrain.
-func (s *Scene) CameraFocus(p vec.I2) {
-	sz := s.View.Size()
-	p = p.Sub(sz.Div(2))
-	p = p.ClampLo(vec.I2{})
-	p = p.ClampHi(s.World.Size().Sub(sz))
-	s.World.SetPosition(p.Mul(-1))
+// within the camera bounds (see Camera.LookAt).
+func (s *Scene) CameraFocus(p vec.I2) { s.Camera.LookAt(p) }
+
+// Draw draws the scene, magnifying the World and parallax layers by the camera
+// zoom.
+func (s *Scene) Draw(screen *ebiten.Image) error {
+	var zoomed []*View
+	if z := s.Camera.zoom(); z > 1 {
+		zoomed = []*View{s.World, s.layers}
+	}
+	return s.dispMerged.draw(screen, zoomed, s.Camera.zoom())
 }
-func (s *Scene) Draw(screen *ebiten.Image) error { return s.dispMerged.draw(screen) }
 
 func (s *Scene) Update() {
+	s.updateLayers()
+
+	updateLocalizedTexts()
+
 	// Reorganise objects to display.
 	s.fixed = s.fixed.gc(s.fixed[:0])
 	s.loose = s.loose.gc(s.loose[:0])
@@ -0,0 +1,470 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// scriptCommands are commands available to scripts, in addition to the built-ins.
+var scriptCommands = make(map[string]func(args []string) error)
+
+// RegisterScriptCommand makes a command available to dialogue scripts as
+// <<name arg1 arg2 ...>>.
+func RegisterScriptCommand(name string, cmd func(args []string) error) {
+	scriptCommands[name] = cmd
+}
+
+// Script is a parsed dialogue script. Scripts are text made of nodes, each
+// starting with a ":: name" header. Within a node, each line is one of:
+//
+//	// comment
+//	Speaker: Text of a line spoken by Speaker.
+//	Text of a line with no speaker.
+//	* Choice label -> node
+//	* [condition] Choice label
+//	<<set name = value>>
+//	<<jump node>>
+//	<<stop>>
+//	<<fire trigger>>
+//	<<if condition>> ... <<elseif condition>> ... <<else>> ... <<endif>>
+//	<<command arg1 arg2 ...>>
+//
+// Choices immediately following a line are shown as buttons with that line.
+// Choosing one jumps to its node, or if no node is given, continues after the
+// choices. Choices with a condition are only shown if the condition is true.
+// Conditions are as for TriggerDef.Condition. Other commands must be registered
+// with RegisterScriptCommand.
+type Script struct {
+	// Avatars are shown for lines spoken by each speaker. Lines by speakers
+	// without an avatar have the speaker's name prepended instead.
+	Avatars map[string]*SheetFrame
+
+	// Slowness is used for every line (see DialogueLine).
+	Slowness int
+
+	// Blips are the text blip sounds (see DialogueLine) for each speaker.
+	Blips map[string]string
+
+	nodes map[string][]scriptStmt
+}
+
+// scriptStmt is one statement in a script node. Which fields are used depends
+// on kind; lineNo is always set, for messages. A line's choices are those that
+// follow it, each jumping to its own target node.
+type scriptStmt struct {
+	kind    stmtKind
+	lineNo  int
+	speaker string         // stmtLine, if it has one
+	text    string         // stmtLine
+	choices []scriptChoice // stmtLine
+	name    string         // stmtSet (variable), stmtJump (node), stmtFire (trigger), stmtCommand (command)
+	args    []string       // stmtSet (value), stmtCommand
+	cases   []scriptBranch // stmtIf
+}
+
+type stmtKind int
+
+const (
+	stmtLine = stmtKind(iota)
+	stmtSet
+	stmtJump
+	stmtStop
+	stmtFire
+	stmtCommand
+	stmtIf
+)
+
+type scriptChoice struct {
+	label  string
+	target string // "" means continue after the choices
+	cond   func() bool
+}
+
+// scriptBranch is one case of an if statement. cond is nil for else.
+type scriptBranch struct {
+	cond  func() bool
+	stmts []scriptStmt
+}
+
+// ParseScript parses a dialogue script.
+func ParseScript(r io.Reader) (*Script, error) {
+	p := &scriptParser{
+		sc:     bufio.NewScanner(r),
+		script: &Script{nodes: make(map[string][]scriptStmt)},
+		jumps:  make(map[string]int),
+	}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	for target, lineNo := range p.jumps {
+		if _, ok := p.script.nodes[target]; !ok {
+			return nil, fmt.Errorf("line %d: unknown node %q", lineNo, target)
+		}
+	}
+	return p.script, nil
+}
+
+type scriptParser struct {
+	sc     *bufio.Scanner
+	script *Script
+	lineNo int
+	jumps  map[string]int // jump targets -> first line number using it
+
+	// Lookahead
+	peeked bool
+	line   string
+}
+
+func (p *scriptParser) next() (string, bool) {
+	if p.peeked {
+		p.peeked = false
+		return p.line, true
+	}
+	for p.sc.Scan() {
+		p.lineNo++
+		l := strings.TrimSpace(p.sc.Text())
+		if l == "" || strings.HasPrefix(l, "//") {
+			continue
+		}
+		p.line = l
+		return l, true
+	}
+	return "", false
+}
+
+func (p *scriptParser) unread() { p.peeked = true }
+
+func (p *scriptParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.lineNo, fmt.Sprintf(format, args...))
+}
+
+func (p *scriptParser) parse() error {
+	for {
+		l, ok := p.next()
+		if !ok {
+			return p.sc.Err()
+		}
+		if !strings.HasPrefix(l, "::") {
+			return p.errorf("expected node header (:: name), got %q", l)
+		}
+		name := strings.TrimSpace(l[2:])
+		if name == "" {
+			return p.errorf("node has no name")
+		}
+		if _, dup := p.script.nodes[name]; dup {
+			return p.errorf("duplicate node %q", name)
+		}
+		stmts, end, err := p.block()
+		if err != nil {
+			return err
+		}
+		if end != "" {
+			return p.errorf("<<%s>> without <<if>>", end)
+		}
+		p.script.nodes[name] = stmts
+	}
+}
+
+// block parses statements until the end of the node, or an <<elseif>>, <<else>>,
+// or <<endif>>, which is returned as end.
+func (p *scriptParser) block() (stmts []scriptStmt, end string, err error) {
+	for {
+		l, ok := p.next()
+		if !ok {
+			return stmts, "", nil
+		}
+		switch {
+		case strings.HasPrefix(l, "::"):
+			p.unread()
+			return stmts, "", nil
+		case strings.HasPrefix(l, "*"):
+			return nil, "", p.errorf("choice without a preceding line")
+		case strings.HasPrefix(l, "<<"):
+			if !strings.HasSuffix(l, ">>") {
+				return nil, "", p.errorf("unterminated command %q", l)
+			}
+			fields := strings.Fields(l[2 : len(l)-2])
+			if len(fields) == 0 {
+				return nil, "", p.errorf("empty command")
+			}
+			switch fields[0] {
+			case "elseif", "else", "endif":
+				p.unread()
+				return stmts, fields[0], nil
+			}
+			s, err := p.command(fields)
+			if err != nil {
+				return nil, "", err
+			}
+			stmts = append(stmts, s)
+		default:
+			s := scriptStmt{kind: stmtLine, lineNo: p.lineNo, text: l}
+			if i := strings.Index(l, ":"); i > 0 && !strings.ContainsAny(l[:i], " \t") {
+				s.speaker, s.text = l[:i], strings.TrimSpace(l[i+1:])
+			}
+			if s.choices, err = p.choices(); err != nil {
+				return nil, "", err
+			}
+			stmts = append(stmts, s)
+		}
+	}
+}
+
+// choices parses the choices following a line.
+func (p *scriptParser) choices() ([]scriptChoice, error) {
+	var cs []scriptChoice
+	for {
+		l, ok := p.next()
+		if !ok {
+			return cs, nil
+		}
+		if !strings.HasPrefix(l, "*") {
+			p.unread()
+			return cs, nil
+		}
+		l = strings.TrimSpace(l[1:])
+		var c scriptChoice
+		if strings.HasPrefix(l, "[") {
+			i := strings.Index(l, "]")
+			if i < 0 {
+				return nil, p.errorf("unterminated choice condition")
+			}
+			cond, err := parseCondition(l[1:i])
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			c.cond = cond
+			l = strings.TrimSpace(l[i+1:])
+		}
+		if i := strings.LastIndex(l, "->"); i >= 0 {
+			c.target = strings.TrimSpace(l[i+2:])
+			if c.target == "" {
+				return nil, p.errorf("choice has no target after ->")
+			}
+			p.jump(c.target)
+			l = strings.TrimSpace(l[:i])
+		}
+		if l == "" {
+			return nil, p.errorf("choice has no label")
+		}
+		c.label = l
+		cs = append(cs, c)
+	}
+}
+
+func (p *scriptParser) jump(target string) {
+	if _, seen := p.jumps[target]; !seen {
+		p.jumps[target] = p.lineNo
+	}
+}
+
+// command parses a command, given its fields.
+func (p *scriptParser) command(fields []string) (scriptStmt, error) {
+	s := scriptStmt{lineNo: p.lineNo, name: fields[0]}
+	args := fields[1:]
+	switch fields[0] {
+	case "set":
+		// <<set name = value>> or <<set name value>>
+		if len(args) == 3 && args[1] == "=" {
+			args = []string{args[0], args[2]}
+		}
+		if len(args) != 2 || !validVarName(args[0]) {
+			return s, p.errorf("want <<set name = value>>")
+		}
+		s.kind, s.name, s.args = stmtSet, args[0], args[1:]
+	case "jump":
+		if len(args) != 1 {
+			return s, p.errorf("want <<jump node>>")
+		}
+		s.kind, s.name = stmtJump, args[0]
+		p.jump(s.name)
+	case "stop":
+		s.kind = stmtStop
+	case "fire":
+		if len(args) != 1 {
+			return s, p.errorf("want <<fire trigger>>")
+		}
+		s.kind, s.name = stmtFire, args[0]
+	case "if":
+		s.kind = stmtIf
+		cond := strings.Join(args, " ")
+		for {
+			c, err := parseCondition(cond)
+			if err != nil {
+				return s, p.errorf("%v", err)
+			}
+			stmts, end, err := p.block()
+			if err != nil {
+				return s, err
+			}
+			s.cases = append(s.cases, scriptBranch{cond: c, stmts: stmts})
+			if end == "" {
+				return s, p.errorf("<<if>> without <<endif>>")
+			}
+			l, _ := p.next() // the end command itself
+			f := strings.Fields(l[2 : len(l)-2])
+			switch end {
+			case "elseif":
+				if cond = strings.Join(f[1:], " "); cond == "" {
+					return s, p.errorf("<<elseif>> without a condition")
+				}
+				continue
+			case "else":
+				stmts, end, err = p.block()
+				if err != nil {
+					return s, err
+				}
+				if end != "endif" {
+					return s, p.errorf("<<else>> without <<endif>>")
+				}
+				p.next()
+				s.cases = append(s.cases, scriptBranch{stmts: stmts})
+			}
+			return s, nil
+		}
+	default:
+		s.kind, s.args = stmtCommand, args
+	}
+	return s, nil
+}
+
+// Play starts playing the script from the named node. Lines are pushed to the
+// front of the dialogue stack one at a time, as each previous line is dismissed.
+func (s *Script) Play(node string) error {
+	r, err := s.runner(node)
+	if err != nil {
+		return err
+	}
+	if l := r.next(); l != nil {
+		PushDialogue(l)
+	}
+	return nil
+}
+
+func (s *Script) runner(node string) (*scriptRunner, error) {
+	stmts, ok := s.nodes[node]
+	if !ok {
+		return nil, fmt.Errorf("script has no node %q", node)
+	}
+	r := &scriptRunner{script: s}
+	r.goTo(node, stmts)
+	return r, nil
+}
+
+// scriptRunner executes a script.
+type scriptRunner struct {
+	script *Script
+	node   string
+	stack  []scriptFrame // innermost block last
+}
+
+type scriptFrame struct {
+	stmts []scriptStmt
+	pc    int
+}
+
+func (r *scriptRunner) goTo(node string, stmts []scriptStmt) {
+	r.node = node
+	r.stack = append(r.stack[:0], scriptFrame{stmts: stmts})
+}
+
+// next runs the script until the next line of dialogue, and returns it, or nil
+// if the script has finished.
+func (r *scriptRunner) next() *DialogueLine {
+	for len(r.stack) > 0 {
+		f := &r.stack[len(r.stack)-1]
+		if f.pc >= len(f.stmts) {
+			r.stack = r.stack[:len(r.stack)-1]
+			continue
+		}
+		s := &f.stmts[f.pc]
+		f.pc++
+		switch s.kind {
+		case stmtLine:
+			if l := r.line(s); l != nil {
+				return l
+			}
+		case stmtSet:
+			SetVar(s.name, s.args[0])
+		case stmtJump:
+			r.goTo(s.name, r.script.nodes[s.name])
+		case stmtStop:
+			r.stack = nil
+		case stmtFire:
+			FireTrigger(s.name)
+		case stmtCommand:
+			cmd, ok := scriptCommands[s.name]
+			if !ok {
+				log.Printf("script node %q line %d: unknown command %q", r.node, s.lineNo, s.name)
+				continue
+			}
+			if err := cmd(s.args); err != nil {
+				log.Printf("script node %q line %d: command %q: %v", r.node, s.lineNo, s.name, err)
+			}
+		case stmtIf:
+			for _, c := range s.cases {
+				if c.cond == nil || c.cond() {
+					r.stack = append(r.stack, scriptFrame{stmts: c.stmts})
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// line converts a line statement into a DialogueLine that continues the script
+// when dismissed or when a choice is made.
+func (r *scriptRunner) line(s *scriptStmt) *DialogueLine {
+	l := &DialogueLine{
+		Avatar:   r.script.Avatars[s.speaker],
+		Name:     s.speaker,
+		Text:     s.text,
+		Slowness: r.script.Slowness,
+		Blip:     r.script.Blips[s.speaker],
+	}
+	if s.speaker != "" && l.Avatar == nil {
+		l.Text = s.speaker + ": " + s.text
+	}
+	cont := func() {
+		if n := r.next(); n != nil {
+			PushDialogue(n)
+		}
+	}
+	for _, c := range s.choices {
+		if c.cond != nil && !c.cond() {
+			continue
+		}
+		c := c
+		l.Buttons = append(l.Buttons, &ButtonSpec{
+			Label: c.label,
+			Action: func() {
+				if c.target != "" {
+					r.goTo(c.target, r.script.nodes[c.target])
+				}
+				cont()
+			},
+		})
+	}
+	if len(l.Buttons) == 0 {
+		l.Done = cont
+	}
+	return l
+}
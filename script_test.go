@@ -0,0 +1,140 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DrJosh9000/vec"
+)
+
+type idleUnit struct{}
+
+func (idleUnit) GoIdle()                    {}
+func (idleUnit) Footprint() (ul, dr vec.I2) { return }
+func (idleUnit) Path() []vec.I2             { return nil }
+
+const testScript = `
+// A test script.
+:: start
+Bob: Hello!
+<<if met_bob>>
+Bob: Good to see you again.
+<<elseif heard_of_bob>>
+Bob: You've heard of me?
+<<else>>
+Bob: I don't think we've met.
+<<set met_bob = yes>>
+<<endif>>
+What do you say?
+* Goodbye -> bye
+* [has_key] Show him the key -> key
+* Nothing
+Bob: Well, OK then.
+
+:: key
+Bob: That's my key!
+<<stop>>
+Unreachable.
+
+:: bye
+Bob: Bye!
+`
+
+// playScript collects the text of lines from the script, choosing the choice
+// with the given label whenever there are choices.
+func playScript(t *testing.T, s *Script, node string, choose ...string) []string {
+	player = idleUnit{}
+	dialogueStack = nil
+	if err := s.Play(node); err != nil {
+		t.Fatalf("Play(%q): %v", node, err)
+	}
+	var got []string
+	for len(dialogueStack) > 0 {
+		l := dialogueStack[0]
+		dialogueStack = dialogueStack[1:]
+		got = append(got, l.Text)
+		if len(l.Buttons) == 0 {
+			l.Done()
+			continue
+		}
+		if len(choose) == 0 {
+			t.Fatalf("Line %q has choices, but none left to choose", l.Text)
+		}
+		for _, b := range l.Buttons {
+			if b.Label == choose[0] {
+				b.Action()
+				break
+			}
+		}
+		choose = choose[1:]
+	}
+	return got
+}
+
+func TestScript(t *testing.T) {
+	s, err := ParseScript(strings.NewReader(testScript))
+	if err != nil {
+		t.Fatalf("ParseScript: %v", err)
+	}
+	vars = make(map[string]string)
+	tests := []struct {
+		choose []string
+		want   string
+	}{
+		{
+			choose: []string{"Nothing"},
+			want:   "Bob: Hello!|Bob: I don't think we've met.|What do you say?|Bob: Well, OK then.",
+		},
+		{
+			choose: []string{"Goodbye"},
+			want:   "Bob: Hello!|Bob: Good to see you again.|What do you say?|Bob: Bye!",
+		},
+	}
+	for _, test := range tests {
+		if got := strings.Join(playScript(t, s, "start", test.choose...), "|"); got != test.want {
+			t.Errorf("Playing with choices %v:\ngot  %q\nwant %q", test.choose, got, test.want)
+		}
+	}
+
+	// The key choice should only be offered with the key.
+	SetVar("has_key", "yes")
+	got := strings.Join(playScript(t, s, "start", "Show him the key"), "|")
+	if want := "Bob: Hello!|Bob: Good to see you again.|What do you say?|Bob: That's my key!"; got != want {
+		t.Errorf("Playing with the key:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestParseScriptErrors(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"Hello", `line 1: expected node header`},
+		{":: a\n* Choice", "line 2: choice without a preceding line"},
+		{":: a\nHi\n* Go -> nowhere", `line 3: unknown node "nowhere"`},
+		{":: a\n<<jump b>>\n:: a", `line 3: duplicate node "a"`},
+		{":: a\n<<if x>>\nHi", "<<if>> without <<endif>>"},
+		{":: a\n<<endif>>", "<<endif>> without <<if>>"},
+		{":: a\n<<set x>>", "line 2: want <<set name = value>>"},
+		{":: a\n<<if x ==>>\n<<endif>>", "line 2: condition"},
+	}
+	for _, test := range tests {
+		_, err := ParseScript(strings.NewReader(test.src))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("ParseScript(%q) error = %v, want error containing %q", test.src, err, test.want)
+		}
+	}
+}
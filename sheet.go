@@ -21,6 +21,10 @@ type FrameInfo struct {
 	Duration int    // in model frames. -1 means infinite, 0 means 1
 	Next     int    // next model frame index, no special meaning for 0...
 	Offset   vec.I2 // subtract from position to get top-left of destination
+
+	// Src, if not empty, is the source rectangle of the frame, for sheets with
+	// frames of different sizes (such as trimmed frames packed by a tool).
+	Src vec.Rect
 }
 
 // BasicFrameInfos is a convenience for making FrameInfos that all have the same
@@ -40,11 +44,48 @@ type Sheet struct {
 	Key        string
 	FrameInfos []FrameInfo
 	FrameSize  vec.I2
+
+	// Anims are the first frames of named animations.
+	Anims map[string]int
+
+	// Slices are named rectangles (such as hitboxes) for some frames.
+	Slices map[string][]SliceKey
+
+	// Copies maps frames that are copies of others to the frame they copy, so
+	// slices can be looked up for either.
+	Copies map[int]int
+}
+
+// SliceKey is a slice rectangle from frame Frame onwards, relative to the
+// top-left of the destination (untrimmed) frame.
+type SliceKey struct {
+	Frame  int
+	Bounds vec.Rect
+	Pivot  vec.I2
+}
+
+// Slice returns the rectangle of the named slice at frame f, if it has one.
+func (s *Sheet) Slice(name string, f int) (vec.Rect, bool) {
+	if src, ok := s.Copies[f]; ok {
+		f = src
+	}
+	var r vec.Rect
+	found := false
+	for _, k := range s.Slices[name] {
+		if k.Frame > f {
+			break
+		}
+		r, found = k.Bounds, true
+	}
+	return r, found
 }
 
 // Src returns the source rectangle for frame number f.
 func (s *Sheet) FrameSrc(f int) (x0, y0, x1, y1 int) {
 	f %= len(s.FrameInfos)
+	if r := s.FrameInfos[f].Src; r.Size() != (vec.I2{}) {
+		return r.C()
+	}
 	if s.Columns == 0 {
 		x0, y0 = vec.NewI2(f, 0).EMul(s.FrameSize).C()
 		x1, y1 = x0+s.FrameSize.X, y0+s.FrameSize.Y
@@ -55,6 +96,12 @@ func (s *Sheet) FrameSrc(f int) (x0, y0, x1, y1 int) {
 	return
 }
 
+// frameSize is the size of frame number f.
+func (s *Sheet) frameSize(f int) vec.I2 {
+	x0, y0, x1, y1 := s.FrameSrc(f)
+	return vec.I2{x1 - x0, y1 - y0}
+}
+
 // SheetFrame lets you specify a frame in addition to a sheet.
 type SheetFrame struct {
 	*Sheet
@@ -0,0 +1,236 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/DrJosh9000/vec"
+)
+
+// packedRect is a rectangle in Aseprite and TexturePacker JSON.
+type packedRect struct {
+	X, Y, W, H int
+}
+
+func (r packedRect) rect() vec.Rect { return vec.Rect{vec.I2{r.X, r.Y}, vec.I2{r.X + r.W, r.Y + r.H}} }
+
+// packedFrame is a frame in Aseprite and TexturePacker JSON.
+type packedFrame struct {
+	Filename         string     `json:"filename"` // only in the array format
+	Frame            packedRect `json:"frame"`
+	Rotated          bool       `json:"rotated"`
+	SpriteSourceSize packedRect `json:"spriteSourceSize"`
+	SourceSize       struct {
+		W, H int
+	} `json:"sourceSize"`
+	Pivot *struct {
+		X, Y float64 // normalised to the source size
+	} `json:"pivot"` // TexturePacker only
+	Duration int `json:"duration"` // milliseconds; Aseprite only
+}
+
+// packedSheet is the JSON exported by Aseprite, or TexturePacker in the JSON
+// (Hash) or JSON (Array) formats.
+type packedSheet struct {
+	Frames json.RawMessage `json:"frames"`
+	Meta   struct {
+		FrameTags []struct {
+			Name      string
+			From, To  int
+			Direction string
+		} `json:"frameTags"`
+		Slices []struct {
+			Name string
+			Keys []struct {
+				Frame  int
+				Bounds packedRect
+				Pivot  *struct{ X, Y int }
+			}
+		} `json:"slices"`
+	} `json:"meta"`
+}
+
+// ImportSheet reads a sheet exported as JSON by Aseprite or TexturePacker, for
+// the image registered as key. Frames may be trimmed; each frame's source
+// rectangle and offset are set so it is drawn where the untrimmed frame would
+// be. Frames are offset by their pivot: from TexturePacker, or from the first
+// Aseprite slice with a pivot, or else the top-left corner.
+//
+// Aseprite durations in milliseconds are rounded to model frames frameMillis
+// long. Frames without a duration (from TexturePacker, or if frameMillis is 0)
+// are shown until the sprite is told otherwise. Aseprite tags become Anims,
+// with a copy of the tagged frames appended to FrameInfos for each (recorded in
+// Copies), played in the tag's direction and looping within the tag. Without
+// tags, each frame name (less extension) is an Anim starting there.
+func ImportSheet(r io.Reader, key string, frameMillis int) (*Sheet, error) {
+	ps := new(packedSheet)
+	if err := json.NewDecoder(r).Decode(ps); err != nil {
+		return nil, fmt.Errorf("decoding sheet: %v", err)
+	}
+	frames, err := orderedFrames(ps.Frames)
+	if err != nil {
+		return nil, err
+	}
+	n := len(frames)
+	if n == 0 {
+		return nil, fmt.Errorf("sheet has no frames")
+	}
+	s := &Sheet{
+		Key:        key,
+		FrameInfos: make([]FrameInfo, n),
+		Anims:      make(map[string]int),
+		Slices:     make(map[string][]SliceKey),
+		Copies:     make(map[int]int),
+	}
+
+	// Slices, and pivots from them.
+	pivots := make([]*vec.I2, n)
+	pivotTaken := false
+	for _, sl := range ps.Meta.Slices {
+		pivotSlice := false
+		for i, k := range sl.Keys {
+			sk := SliceKey{Frame: k.Frame, Bounds: k.Bounds.rect()}
+			if k.Pivot != nil {
+				sk.Pivot = sk.Bounds.UL.Add(vec.I2{k.Pivot.X, k.Pivot.Y})
+				pivotSlice = true
+			}
+			s.Slices[sl.Name] = append(s.Slices[sl.Name], sk)
+			if k.Pivot == nil || pivotTaken {
+				continue
+			}
+			end := n
+			if i+1 < len(sl.Keys) {
+				end = sl.Keys[i+1].Frame
+			}
+			for f := k.Frame; f < end && f < n; f++ {
+				if pivots[f] == nil {
+					p := sk.Pivot
+					pivots[f] = &p
+				}
+			}
+		}
+		if pivotSlice {
+			pivotTaken = true
+		}
+	}
+
+	for i, f := range frames {
+		if f.Rotated {
+			return nil, fmt.Errorf("frame %d (%q) is rotated, which isn't supported", i, f.Filename)
+		}
+		var pivot vec.I2
+		switch {
+		case f.Pivot != nil:
+			pivot = vec.I2{int(f.Pivot.X * float64(f.SourceSize.W)), int(f.Pivot.Y * float64(f.SourceSize.H))}
+		case pivots[i] != nil:
+			pivot = *pivots[i]
+		}
+		// Frames without a duration are still images: they last forever.
+		dur, next := -1, i
+		if f.Duration > 0 && frameMillis > 0 {
+			if dur = (f.Duration + frameMillis/2) / frameMillis; dur < 1 {
+				dur = 1
+			}
+			next = (i + 1) % n
+		}
+		s.FrameInfos[i] = FrameInfo{
+			Duration: dur,
+			Next:     next,
+			Offset:   pivot.Sub(vec.I2{f.SpriteSourceSize.X, f.SpriteSourceSize.Y}),
+			Src:      f.Frame.rect(),
+		}
+		if f.SourceSize.W > s.FrameSize.X {
+			s.FrameSize.X = f.SourceSize.W
+		}
+		if f.SourceSize.H > s.FrameSize.Y {
+			s.FrameSize.Y = f.SourceSize.H
+		}
+		if len(ps.Meta.FrameTags) == 0 && f.Filename != "" {
+			name := f.Filename
+			if i := strings.LastIndex(name, "."); i > 0 {
+				name = name[:i]
+			}
+			s.Anims[name] = i
+		}
+	}
+
+	for _, t := range ps.Meta.FrameTags {
+		if t.From < 0 || t.To >= n || t.From > t.To {
+			return nil, fmt.Errorf("tag %q has bad frame range [%d, %d]", t.Name, t.From, t.To)
+		}
+		var seq []int
+		for f := t.From; f <= t.To; f++ {
+			seq = append(seq, f)
+		}
+		switch t.Direction {
+		case "", "forward":
+		case "reverse":
+			for i, j := 0, len(seq)-1; i < j; i, j = i+1, j-1 {
+				seq[i], seq[j] = seq[j], seq[i]
+			}
+		case "pingpong":
+			for f := t.To - 1; f > t.From; f-- {
+				seq = append(seq, f)
+			}
+		default:
+			return nil, fmt.Errorf("tag %q has unknown direction %q", t.Name, t.Direction)
+		}
+		start := len(s.FrameInfos)
+		for j, f := range seq {
+			fi := s.FrameInfos[f]
+			fi.Next = start + (j+1)%len(seq)
+			s.Copies[len(s.FrameInfos)] = f
+			s.FrameInfos = append(s.FrameInfos, fi)
+		}
+		s.Anims[t.Name] = start
+	}
+	return s, nil
+}
+
+// orderedFrames decodes frames in either the array format, or the hash format
+// (keeping the order of the keys, which JSON objects otherwise lose).
+func orderedFrames(raw json.RawMessage) ([]packedFrame, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '[' {
+		var frames []packedFrame
+		if err := json.Unmarshal(raw, &frames); err != nil {
+			return nil, fmt.Errorf("decoding frames: %v", err)
+		}
+		return frames, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, fmt.Errorf("frames is neither an array nor an object")
+	}
+	var frames []packedFrame
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("decoding frames: %v", err)
+		}
+		var f packedFrame
+		if err := dec.Decode(&f); err != nil {
+			return nil, fmt.Errorf("decoding frame %q: %v", t, err)
+		}
+		f.Filename = t.(string)
+		frames = append(frames, f)
+	}
+	return frames, nil
+}
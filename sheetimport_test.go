@@ -0,0 +1,159 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DrJosh9000/vec"
+)
+
+const testAseprite = `{
+  "frames": {
+    "hero 10.aseprite": {"frame": {"x": 0, "y": 0, "w": 16, "h": 16}, "rotated": false, "trimmed": false,
+      "spriteSourceSize": {"x": 0, "y": 0, "w": 16, "h": 16}, "sourceSize": {"w": 16, "h": 16}, "duration": 100},
+    "hero 2.aseprite": {"frame": {"x": 16, "y": 0, "w": 10, "h": 12}, "rotated": false, "trimmed": true,
+      "spriteSourceSize": {"x": 3, "y": 4, "w": 10, "h": 12}, "sourceSize": {"w": 16, "h": 16}, "duration": 50},
+    "hero 3.aseprite": {"frame": {"x": 26, "y": 0, "w": 16, "h": 16}, "rotated": false, "trimmed": false,
+      "spriteSourceSize": {"x": 0, "y": 0, "w": 16, "h": 16}, "sourceSize": {"w": 16, "h": 16}, "duration": 10}
+  },
+  "meta": {
+    "frameTags": [{"name": "walk", "from": 0, "to": 2, "direction": "pingpong"}],
+    "slices": [
+      {"name": "feet", "keys": [{"frame": 0, "bounds": {"x": 6, "y": 14, "w": 4, "h": 2}, "pivot": {"x": 2, "y": 2}}]},
+      {"name": "hitbox", "keys": [{"frame": 0, "bounds": {"x": 4, "y": 2, "w": 8, "h": 14}}]},
+      {"name": "hurtbox", "keys": [
+        {"frame": 1, "bounds": {"x": 0, "y": 0, "w": 2, "h": 2}},
+        {"frame": 2, "bounds": {"x": 1, "y": 1, "w": 2, "h": 2}}
+      ]}
+    ]
+  }
+}`
+
+func TestImportAseprite(t *testing.T) {
+	s, err := ImportSheet(strings.NewReader(testAseprite), "hero", 16)
+	if err != nil {
+		t.Fatalf("ImportSheet: %v", err)
+	}
+	pivot := vec.I2{8, 16} // from the feet slice
+	want := []FrameInfo{
+		// Frames in file order (not sorted by name).
+		{Duration: 6, Next: 1, Offset: pivot, Src: vec.Rect{vec.I2{0, 0}, vec.I2{16, 16}}},
+		{Duration: 3, Next: 2, Offset: pivot.Sub(vec.I2{3, 4}), Src: vec.Rect{vec.I2{16, 0}, vec.I2{26, 12}}},
+		{Duration: 1, Next: 0, Offset: pivot, Src: vec.Rect{vec.I2{26, 0}, vec.I2{42, 16}}},
+		// walk: 0, 1, 2, 1, looping.
+		{Duration: 6, Next: 4, Offset: pivot, Src: vec.Rect{vec.I2{0, 0}, vec.I2{16, 16}}},
+		{Duration: 3, Next: 5, Offset: pivot.Sub(vec.I2{3, 4}), Src: vec.Rect{vec.I2{16, 0}, vec.I2{26, 12}}},
+		{Duration: 1, Next: 6, Offset: pivot, Src: vec.Rect{vec.I2{26, 0}, vec.I2{42, 16}}},
+		{Duration: 3, Next: 3, Offset: pivot.Sub(vec.I2{3, 4}), Src: vec.Rect{vec.I2{16, 0}, vec.I2{26, 12}}},
+	}
+	if !reflect.DeepEqual(s.FrameInfos, want) {
+		t.Errorf("FrameInfos =\n%v\nwant\n%v", s.FrameInfos, want)
+	}
+	if got, want := s.Anims, map[string]int{"walk": 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Anims = %v, want %v", got, want)
+	}
+	if got, want := s.FrameSize, (vec.I2{16, 16}); got != want {
+		t.Errorf("FrameSize = %v, want %v", got, want)
+	}
+	// Slices after the one with the pivot are kept.
+	if r, ok := s.Slice("hitbox", 2); !ok || r != (vec.Rect{vec.I2{4, 2}, vec.I2{12, 16}}) {
+		t.Errorf("Slice(hitbox, 2) = %v, %t", r, ok)
+	}
+	// Tagged frames have the slices of the frames they copy.
+	hurt := []struct {
+		frame int
+		want  vec.Rect
+		ok    bool
+	}{
+		{0, vec.Rect{}, false},
+		{1, vec.Rect{vec.I2{0, 0}, vec.I2{2, 2}}, true},
+		{3, vec.Rect{}, false}, // walk, frame 0
+		{4, vec.Rect{vec.I2{0, 0}, vec.I2{2, 2}}, true},
+		{5, vec.Rect{vec.I2{1, 1}, vec.I2{3, 3}}, true},
+		{6, vec.Rect{vec.I2{0, 0}, vec.I2{2, 2}}, true}, // walk, frame 1 again
+	}
+	for _, h := range hurt {
+		if r, ok := s.Slice("hurtbox", h.frame); r != h.want || ok != h.ok {
+			t.Errorf("Slice(hurtbox, %d) = %v, %t; want %v, %t", h.frame, r, ok, h.want, h.ok)
+		}
+	}
+	if got, want := s.frameSize(1), (vec.I2{10, 12}); got != want {
+		t.Errorf("frameSize(1) = %v, want %v", got, want)
+	}
+}
+
+const testTexturePacker = `{
+  "frames": [
+    {"filename": "door_open.png", "frame": {"x": 2, "y": 2, "w": 20, "h": 30}, "rotated": false, "trimmed": true,
+      "spriteSourceSize": {"x": 6, "y": 2, "w": 20, "h": 30}, "sourceSize": {"w": 32, "h": 32}, "pivot": {"x": 0.5, "y": 1}},
+    {"filename": "door_shut.png", "frame": {"x": 24, "y": 2, "w": 32, "h": 32}, "rotated": false, "trimmed": false,
+      "spriteSourceSize": {"x": 0, "y": 0, "w": 32, "h": 32}, "sourceSize": {"w": 32, "h": 32}, "pivot": {"x": 0.5, "y": 1}}
+  ],
+  "meta": {"image": "doors.png", "size": {"w": 64, "h": 64}}
+}`
+
+func TestImportTexturePacker(t *testing.T) {
+	s, err := ImportSheet(strings.NewReader(testTexturePacker), "doors", 16)
+	if err != nil {
+		t.Fatalf("ImportSheet: %v", err)
+	}
+	want := []FrameInfo{
+		{Duration: -1, Next: 0, Offset: vec.I2{10, 30}, Src: vec.Rect{vec.I2{2, 2}, vec.I2{22, 32}}},
+		{Duration: -1, Next: 1, Offset: vec.I2{16, 32}, Src: vec.Rect{vec.I2{24, 2}, vec.I2{56, 34}}},
+	}
+	if !reflect.DeepEqual(s.FrameInfos, want) {
+		t.Errorf("FrameInfos =\n%v\nwant\n%v", s.FrameInfos, want)
+	}
+	if got, want := s.Anims, map[string]int{"door_open": 0, "door_shut": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Anims = %v, want %v", got, want)
+	}
+
+	// A still frame stays put.
+	sp := &Sprite{View: &View{}, SpriteDelegate: sheetDelegate{sheet: s}}
+	sp.SetFrame(s.Anims["door_open"])
+	for i := 0; i < 5; i++ {
+		sp.AdvanceAnim()
+	}
+	if got, want := sp.f, s.Anims["door_open"]; got != want {
+		t.Errorf("after AdvanceAnim, frame = %d, want %d", got, want)
+	}
+}
+
+// sheetDelegate is a SpriteDelegate that always uses one sheet.
+type sheetDelegate struct {
+	testSpriteDelegate
+	sheet *Sheet
+}
+
+func (d sheetDelegate) SpriteSheet(*Sprite) *Sheet { return d.sheet }
+
+func TestImportSheetErrors(t *testing.T) {
+	for _, test := range []struct {
+		json, want string
+	}{
+		{`{"frames": []}`, "no frames"},
+		{`{"frames": [{"filename": "a", "rotated": true}]}`, "rotated"},
+		{`{"frames": [{}], "meta": {"frameTags": [{"name": "x", "from": 0, "to": 3}]}}`, `tag "x" has bad frame range`},
+		{`{"frames": [{}], "meta": {"frameTags": [{"name": "x", "direction": "sideways"}]}}`, `unknown direction "sideways"`},
+	} {
+		_, err := ImportSheet(strings.NewReader(test.json), "k", 16)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("ImportSheet(%s) error = %v, want %q", test.json, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import "github.com/DrJosh9000/vec"
+
+const (
+	speechTailGap  = 6   // space between a speaker and their bubble, for the tail
+	speechMaxWidth = 120 // maximum width of text in anchored bubbles
+	barkZ          = 50  // Z of barks within the HUD (below dialogue)
+)
+
+var barks []*bark
+
+// BubbleTailer can optionally be implemented by a Game to provide the image
+// drawn under speech bubbles anchored to speakers, pointing down at them.
+type BubbleTailer interface {
+	BubbleTailKey() string
+}
+
+// bubbleTail is the tail of a speech bubble. It is drawn centred on x, just
+// below the bottom of the containing view.
+type bubbleTail struct {
+	*View
+	key   string
+	x     int
+	added bool
+}
+
+func newBubbleTail(parent *View) *bubbleTail {
+	bt, ok := game.(BubbleTailer)
+	if !ok {
+		return nil
+	}
+	t := &bubbleTail{View: &View{}, key: bt.BubbleTailKey()}
+	t.SetParent(parent)
+	t.SetZ(2)
+	return t
+}
+
+func (t *bubbleTail) ImageKey() string { return t.key }
+
+func (t *bubbleTail) Src() (x0, y0, x1, y1 int) {
+	x1, y1 = sizes[t.key].C()
+	return
+}
+
+func (t *bubbleTail) Dst() (x0, y0, x1, y1 int) {
+	sz := sizes[t.key]
+	// Overlap the bottom edge of the bubble by one pixel, to join them up.
+	x0, y0 = t.x-sz.X/2, t.parent.Size().Y-1
+	return x0, y0, x0 + sz.X, y0 + sz.Y
+}
+
+// anchorAbove positions something of the given size centred above the speaker,
+// keeping it on screen. If there is no room above, it goes below the speaker
+// instead. It returns the position relative to the HUD, and the x position
+// (relative to the returned position) for a tail pointing at the speaker, or
+// -1 if the tail shouldn't be shown.
+func anchorAbove(size vec.I2, speaker *Sprite) (pos vec.I2, tailX int) {
+	hud := scene.HUD.Position()
+	r := scene.Camera.toScreen(canonRect(drawPosition{speaker}.Dst())).Translate(hud.Mul(-1))
+	anchor := vec.I2{(r.UL.X + r.DR.X) / 2, r.UL.Y}
+	screen := scene.HUD.Size()
+
+	pos = vec.I2{anchor.X - size.X/2, anchor.Y - speechTailGap - size.Y}
+	tailX = -1
+	if pos.Y >= 0 {
+		tailX = 0
+	} else {
+		pos.Y = r.DR.Y + speechTailGap
+	}
+	pos = pos.ClampHi(screen.Sub(size)).ClampLo(vec.I2{})
+	if tailX == 0 {
+		// Keep the tail clear of the bubble's corners.
+		min, max := 2*bubblePartSize.X, size.X-2*bubblePartSize.X
+		tailX = anchor.X - pos.X
+		if tailX < min {
+			tailX = min
+		}
+		if tailX > max {
+			tailX = max
+		}
+	}
+	return pos, tailX
+}
+
+// bark is a speech bubble that doesn't block the game or wait for input.
+type bark struct {
+	*View
+	bubble  *Bubble
+	text    *Text
+	tail    *bubbleTail
+	speaker *Sprite
+	frames  int // remaining lifetime in model frames
+}
+
+// Bark shows text in a bubble above the speaker for a number of model frames,
+// without blocking the game. Any number of barks can be shown at once.
+func Bark(speaker *Sprite, text string, frames int) {
+	bk, _ := game.BubbleKey()
+	b := &bark{
+		View:    &View{},
+		bubble:  &Bubble{View: &View{}, Key: bk},
+		text:    &Text{View: &View{}, Font: game.Font(), BoldFont: gameBoldFont(), Text: text},
+		speaker: speaker,
+		frames:  frames,
+	}
+	b.SetParent(scene.HUD)
+	b.SetZ(barkZ)
+	b.bubble.SetParent(b.View)
+	b.bubble.SetZ(1)
+	b.text.SetParent(b.bubble.View)
+	b.text.SetZ(1)
+	b.text.SetPositionAndSize(bubblePartSize.Mul(2), vec.I2{speechMaxWidth, 0})
+	b.text.Layout(true)
+	size := b.text.Size().Add(bubblePartSize.Mul(4))
+	b.SetSize(size)
+	b.bubble.SetSize(size)
+	b.tail = newBubbleTail(b.View)
+
+	b.bubble.AddToScene(scene)
+	b.text.AddToScene(scene)
+	if b.tail != nil {
+		scene.AddPart(b.tail)
+	}
+	barks = append(barks, b)
+	layoutBarks()
+}
+
+// updateBarks counts down one model frame, removes expired barks, and
+// repositions the rest.
+func updateBarks() {
+	live := barks[:0]
+	for _, b := range barks {
+		if b.frames <= 0 || b.speaker.Retire() {
+			b.Dispose()
+			continue
+		}
+		b.frames--
+		live = append(live, b)
+	}
+	for i := len(live); i < len(barks); i++ {
+		barks[i] = nil
+	}
+	barks = live
+	layoutBarks()
+}
+
+// layoutBarks positions barks to follow their speakers. Each bark is moved
+// upwards until it clears the barks already placed, from any speaker. A bark
+// that would have to go above the top of the screen to do so stays where it
+// is, overlapping.
+func layoutBarks() {
+	var placed []vec.Rect
+	for _, b := range barks {
+		pos, tailX := anchorAbove(b.Size(), b.speaker)
+		r := vec.Rect{pos, pos.Add(b.Size())}
+		// Moving above one bark can overlap another; keep going until clear.
+		for moved := true; moved; {
+			moved = false
+			for _, p := range placed {
+				if r.Overlaps(p) && p.UL.Y-r.Size().Y >= 0 {
+					r = r.Reposition(vec.I2{r.UL.X, p.UL.Y - r.Size().Y})
+					tailX = -1
+					moved = true
+				}
+			}
+		}
+		placed = append(placed, r)
+		b.SetPosition(r.UL)
+		if b.tail != nil {
+			b.tail.x = tailX
+			b.tail.SetVisible(tailX >= 0)
+		}
+	}
+}
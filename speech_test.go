@@ -0,0 +1,128 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"testing"
+
+	"github.com/DrJosh9000/vec"
+)
+
+// testSpriteDelegate draws a sprite as a 10x20 frame standing on its position.
+type testSpriteDelegate struct{}
+
+var testSpriteSheet = &Sheet{
+	Key:        "test",
+	FrameSize:  vec.I2{10, 20},
+	FrameInfos: []FrameInfo{{Offset: vec.I2{5, 20}}},
+}
+
+func (testSpriteDelegate) Fixed(*Sprite) bool         { return false }
+func (testSpriteDelegate) SpriteSheet(*Sprite) *Sheet { return testSpriteSheet }
+func (testSpriteDelegate) Update(*Sprite, int)        {}
+func (testSpriteDelegate) Z(*Sprite) int              { return 0 }
+
+func testSpeaker(x, y float64) *Sprite {
+	return &Sprite{View: &View{}, Pos: vec.F2{x, y}, SpriteDelegate: testSpriteDelegate{}}
+}
+
+// barkGame provides what Bark needs from a Game.
+type barkGame struct{ Game }
+
+func (barkGame) BubbleKey() (string, string) { return "bubble", "bubble-inverse" }
+func (barkGame) Font() Font                  { return newTestFont("abcdefghijklmnopqrstuvwxyz ") }
+
+func TestAnchorAbove(t *testing.T) {
+	defer func(s *Scene) { scene = s }(scene)
+	scene = NewScene(vec.I2{200, 100}, vec.I2{200, 100})
+
+	size := vec.I2{40, 20}
+	tests := []struct {
+		speaker   vec.F2
+		wantPos   vec.I2
+		wantTailX int
+	}{
+		{vec.F2{100, 60}, vec.I2{80, 14}, 20},  // centred above
+		{vec.F2{5, 60}, vec.I2{0, 14}, 10},     // clamped to the left edge, tail clear of the corner
+		{vec.F2{195, 60}, vec.I2{160, 14}, 30}, // clamped to the right edge
+		{vec.F2{100, 25}, vec.I2{80, 31}, -1},  // no room above: below, without a tail
+		{vec.F2{100, 99}, vec.I2{80, 53}, 20},
+	}
+	for _, test := range tests {
+		pos, tailX := anchorAbove(size, testSpeaker(test.speaker.X, test.speaker.Y))
+		if pos != test.wantPos || tailX != test.wantTailX {
+			t.Errorf("speaker at %v: anchorAbove = %v, %d; want %v, %d", test.speaker, pos, tailX, test.wantPos, test.wantTailX)
+		}
+	}
+}
+
+func TestBarks(t *testing.T) {
+	defer func(g Game, s *Scene) { game, scene, barks = g, s, nil }(game, scene)
+	game = barkGame{}
+	scene = NewScene(vec.I2{200, 100}, vec.I2{200, 100})
+
+	speaker := testSpeaker(100, 90)
+	Bark(speaker, "hello", 3)
+	Bark(speaker, "again", 2)
+	first, second := barks[0], barks[1]
+	size := first.Size()
+
+	// The second bark is stacked above the first.
+	want := vec.I2{100 - size.X/2, 70 - speechTailGap - size.Y}
+	if got := first.RelativeBounds().UL; got != want {
+		t.Errorf("first bark at %v, want %v", got, want)
+	}
+	want.Y -= second.Size().Y
+	if got := second.RelativeBounds().UL; got != want {
+		t.Errorf("second bark at %v, want %v", got, want)
+	}
+
+	// Each bark lasts as many model frames as asked.
+	for frame, want := range []int{2, 2, 1, 0} {
+		updateBarks()
+		if got := len(barks); got != want {
+			t.Errorf("after %d updates: %d barks, want %d", frame+1, got, want)
+		}
+	}
+	if !second.Retire() || !first.Retire() {
+		t.Errorf("expired barks not disposed")
+	}
+}
+
+func TestBarksDontOverlap(t *testing.T) {
+	defer func(g Game, s *Scene) { game, scene, barks = g, s, nil }(game, scene)
+	game = barkGame{}
+	scene = NewScene(vec.I2{200, 200}, vec.I2{200, 200})
+
+	// Bubbles are 40x30. The third starts over the second, and moving above
+	// it puts it over the first, from a different speaker.
+	Bark(testSpeaker(60, 161), "hello", 3)
+	Bark(testSpeaker(104, 196), "hello", 3)
+	Bark(testSpeaker(90, 196), "hello", 3)
+	rects := make([]vec.Rect, len(barks))
+	for i, b := range barks {
+		rects[i] = b.RelativeBounds()
+	}
+	for i := range rects {
+		for j := i + 1; j < len(rects); j++ {
+			if rects[i].Overlaps(rects[j]) {
+				t.Errorf("bark %d at %v overlaps bark %d at %v", i, rects[i], j, rects[j])
+			}
+		}
+	}
+	if got, want := rects[2].UL.Y, 75; got != want {
+		t.Errorf("third bark at y %d, want %d", got, want)
+	}
+}
@@ -14,7 +14,20 @@
 
 package awakengine
 
-import "github.com/DrJosh9000/vec"
+import (
+	"image/color"
+
+	"github.com/DrJosh9000/vec"
+)
+
+// sprites are sprites registered by name, so they can be referred to from data files.
+var sprites = make(map[string]*Sprite)
+
+// RegisterSprite makes a sprite available by name, e.g. to play animations from
+// data-driven triggers.
+func RegisterSprite(name string, s *Sprite) {
+	sprites[name] = s
+}
 
 type SpriteDelegate interface {
 	// Instancey things
@@ -32,9 +45,32 @@ type Sprite struct {
 	f     int    // current frame of sprite sheet
 	fd    int    // duration of current frame
 	SpriteDelegate
+
+	Colour color.Color // tint (see Tinted), such as Faded(0.5) or a damage flash
+	Geom   Transform   // flips etc; the pivot is always the sprite's position
+}
+
+// Tint implements Tinted.
+func (s *Sprite) Tint() color.Color { return s.Colour }
+
+// Transform implements Transformed, pivoting about Pos.
+func (s *Sprite) Transform() Transform {
+	t := s.Geom
+	if t == (Transform{}) {
+		return t
+	}
+	sheet := s.SpriteSheet(s)
+	if s.f < len(sheet.FrameInfos) {
+		t.Pivot = sheet.FrameInfos[s.f].Offset
+	}
+	return t
 }
 
 func (s *Sprite) ResetAnim() { s.f, s.fd = 0, -1 }
+
+// SetFrame jumps the animation to frame f of the current sheet.
+func (s *Sprite) SetFrame(f int) { s.f, s.fd = f, -1 }
+
 func (s *Sprite) AdvanceAnim() {
 	sheet := s.SpriteSheet(s)
 	infos := sheet.FrameInfos
@@ -70,7 +106,7 @@ func (s *Sprite) Dst() (x0, y0, x1, y1 int) {
 	info := &sheet.FrameInfos[s.f]
 	ul := s.Pos.I2().Sub(info.Offset)
 	x0, y0 = ul.C()
-	x1, y1 = ul.Add(s.SpriteSheet(s).FrameSize).C()
+	x1, y1 = ul.Add(sheet.frameSize(s.f)).C()
 	return
 }
 
@@ -173,7 +173,7 @@ func (t *Terrain) MakeAllVisible() {
 }
 
 func (t *Terrain) Fixed() bool  { return true }
-func (t *Terrain) Retire() bool { return false }
+func (t *Terrain) Retire() bool { return t.View.Retire() }
 
 type ray struct {
 	*Terrain
@@ -0,0 +1,253 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import "github.com/DrJosh9000/vec"
+
+// Alignment is the horizontal alignment of lines of Text.
+type Alignment int
+
+const (
+	AlignLeft = Alignment(iota)
+	AlignCentre
+	AlignRight
+	AlignJustify // wrapped lines are stretched to the full width
+)
+
+// VAlignment is the vertical alignment of Text within its view.
+type VAlignment int
+
+const (
+	VAlignTop = VAlignment(iota)
+	VAlignMiddle
+	VAlignBottom
+)
+
+// placedGlyph is a character (or icon) positioned by layoutText.
+type placedGlyph struct {
+	item int // index into the items, or len(items) for ellipsis dots
+	font Font
+	c    rune
+	ci   CharInfo
+	icon *SheetFrame
+	pos  vec.I2
+	word int // index of the word within the line
+}
+
+func (g *placedGlyph) advance() int {
+	if g.icon != nil {
+		return g.icon.FrameSize.X + 1
+	}
+	return g.ci.XAdvance
+}
+
+// textLine is a line of glyphs, glyphs[first:end].
+type textLine struct {
+	first, end int
+	width      int
+	words      int
+	wrapped    bool // line ended by wrapping, rather than a newline or the end
+}
+
+// textLayout is text laid out without creating any parts.
+type textLayout struct {
+	glyphs    []placedGlyph
+	lines     []textLine
+	size      vec.I2
+	truncated bool
+}
+
+// MeasureText returns the size of the text (which may contain markup) in the
+// font, wrapped to width. If width <= 0 the text is not wrapped.
+func MeasureText(font Font, s string, width int) vec.I2 {
+	return layoutText(font, font, parseMarkup(s), width, 0, AlignLeft).size
+}
+
+// layoutText does word-wrapping, kerning, truncation to maxLines (if positive)
+// with an ellipsis, and horizontal alignment. The size is the size of the text,
+// except that it is the full width for alignments other than AlignLeft.
+func layoutText(font, bold Font, items []markupItem, width, maxLines int, align Alignment) *textLayout {
+	l := new(textLayout)
+	cm, bm := font.Metrics(), bold.Metrics()
+	lh := font.LineHeight()
+
+	var (
+		line      = textLine{}
+		x, y      int           // position of the next word
+		space     int           // pending space before the next word
+		word      []placedGlyph // glyphs of the current word, positioned relative to the word
+		wordWidth int
+	)
+	newLine := func(wrapped bool) {
+		line.end, line.wrapped = len(l.glyphs), wrapped
+		l.lines = append(l.lines, line)
+		line = textLine{first: len(l.glyphs)}
+		x, space = 0, 0
+		y += lh
+	}
+	flushWord := func() {
+		if len(word) == 0 {
+			return
+		}
+		if width > 0 && line.words > 0 && x+space+wordWidth > width {
+			newLine(true)
+		}
+		x += space
+		for _, g := range word {
+			g.pos = g.pos.Add(vec.I2{x, y})
+			g.word = line.words
+			l.glyphs = append(l.glyphs, g)
+		}
+		x += wordWidth
+		line.width = x
+		line.words++
+		space, word, wordWidth = 0, word[:0], 0
+	}
+	for i, it := range items {
+		f, m := font, cm
+		if it.style.bold {
+			f, m = bold, bm
+		}
+		switch {
+		case it.icon != nil:
+		case it.c == '\n':
+			flushWord()
+			newLine(false)
+			continue
+		case it.c == ' ':
+			flushWord()
+			space += glyph(f, m, ' ').XAdvance
+			continue
+		}
+		g := placedGlyph{item: i, font: f, c: it.c, icon: it.icon}
+		if it.icon == nil {
+			g.ci = glyph(f, m, it.c)
+		}
+		if n := len(word); n > 0 {
+			wordWidth += kern(&word[n-1], &g)
+		}
+		g.pos = vec.I2{wordWidth, 0}
+		word = append(word, g)
+		wordWidth += g.advance()
+	}
+	flushWord()
+	line.end = len(l.glyphs)
+	l.lines = append(l.lines, line)
+
+	if maxLines > 0 && len(l.lines) > maxLines {
+		l.truncate(items, font, maxLines, width)
+	}
+
+	// Alignment.
+	boxW := width
+	maxW := 0
+	for _, ln := range l.lines {
+		if ln.width > maxW {
+			maxW = ln.width
+		}
+	}
+	if boxW <= 0 || align == AlignLeft {
+		boxW = maxW
+	}
+	for _, ln := range l.lines {
+		extra := boxW - ln.width
+		for i := ln.first; i < ln.end; i++ {
+			switch align {
+			case AlignCentre:
+				l.glyphs[i].pos.X += extra / 2
+			case AlignRight:
+				l.glyphs[i].pos.X += extra
+			}
+		}
+		if align == AlignJustify && ln.wrapped && ln.words > 1 {
+			// Spread the extra space between words.
+			for i := ln.first; i < ln.end; i++ {
+				l.glyphs[i].pos.X += extra * l.glyphs[i].word / (ln.words - 1)
+			}
+		}
+	}
+	l.size = vec.I2{boxW, len(l.lines) * lh}
+	return l
+}
+
+// page keeps only n lines starting at line first, moved to the top.
+func (l *textLayout) page(first, n, lineHeight int) {
+	if first+n > len(l.lines) {
+		n = len(l.lines) - first
+	}
+	lines := l.lines[first : first+n]
+	g0 := lines[0].first
+	l.glyphs = l.glyphs[g0:lines[n-1].end]
+	for i := range l.glyphs {
+		l.glyphs[i].pos.Y -= first * lineHeight
+	}
+	for i := range lines {
+		lines[i].first -= g0
+		lines[i].end -= g0
+	}
+	l.lines = lines
+	l.size.Y = n * lineHeight
+}
+
+// kern returns the kerning adjustment between consecutive glyphs a and b.
+func kern(a, b *placedGlyph) int {
+	if a.icon != nil || b.icon != nil || a.font != b.font {
+		return 0
+	}
+	if k, ok := a.font.(KerningFont); ok {
+		return k.Kern(a.c, b.c)
+	}
+	return 0
+}
+
+// truncate removes lines after maxLines, and ends the last line with an
+// ellipsis (or three dots, if the font has no ellipsis), removing glyphs from
+// the end of the line to make room.
+func (l *textLayout) truncate(items []markupItem, font Font, maxLines, width int) {
+	l.truncated = true
+	l.lines = l.lines[:maxLines]
+	last := &l.lines[maxLines-1]
+	l.glyphs = l.glyphs[:last.end]
+
+	m := font.Metrics()
+	dots := []rune{'…'}
+	if _, ok := m['…']; !ok {
+		dots = []rune{'.', '.', '.'}
+	}
+	dotsW := 0
+	for _, d := range dots {
+		dotsW += glyph(font, m, d).XAdvance
+	}
+	end := last.width
+	for width > 0 && last.end > last.first && end+dotsW > width {
+		last.end--
+		end = l.glyphs[last.end].pos.X
+	}
+	// Drop trailing spaces, which aren't glyphs but are part of the line width.
+	if last.end > last.first {
+		g := &l.glyphs[last.end-1]
+		end = g.pos.X + g.advance()
+	}
+	l.glyphs = l.glyphs[:last.end]
+	y := (maxLines - 1) * font.LineHeight()
+	for _, d := range dots {
+		g := placedGlyph{item: len(items), font: font, c: d, ci: glyph(font, m, d), pos: vec.I2{end, y}}
+		l.glyphs = append(l.glyphs, g)
+		end += g.advance()
+	}
+	last.end = len(l.glyphs)
+	last.width = end
+	last.wrapped = false
+}
@@ -0,0 +1,199 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"strings"
+
+	"github.com/DrJosh9000/vec"
+)
+
+const backlogZ = 200 // above dialogue
+
+var (
+	transcript []TranscriptEntry
+	backlog    *backlogPanel
+)
+
+// TranscriptEntry is a line of dialogue that was shown, or a choice that was made.
+type TranscriptEntry struct {
+	Frame   int    `json:"frame"`             // model frame when it was shown or chosen
+	Speaker string `json:"speaker,omitempty"` // DialogueLine.Name
+	Text    string `json:"text"`              // the text as shown, or the choice label
+	Choice  bool   `json:"choice,omitempty"`
+}
+
+// String formats the entry as it appears in the backlog.
+func (e TranscriptEntry) String() string {
+	switch {
+	case e.Choice:
+		return "> " + e.Text
+	case e.Speaker != "" && !strings.HasPrefix(e.Text, e.Speaker+":"):
+		return e.Speaker + ": " + e.Text
+	}
+	return e.Text
+}
+
+// Transcript returns the dialogue shown so far, oldest first.
+func Transcript() []TranscriptEntry {
+	return append([]TranscriptEntry(nil), transcript...)
+}
+
+// ClearTranscript forgets all the dialogue shown so far.
+func ClearTranscript() {
+	transcript = nil
+}
+
+func record(e TranscriptEntry) {
+	e.Frame = modelFrame
+	transcript = append(transcript, e)
+	if n := config.TranscriptLimit; n > 0 && len(transcript) > n {
+		transcript = append(transcript[:0], transcript[len(transcript)-n:]...)
+	}
+}
+
+// ShowBacklog opens a panel with the transcript, scrolled to the most recent
+// lines. The game is paused while it is open.
+func ShowBacklog() {
+	if backlog != nil {
+		return
+	}
+	backlog = newBacklogPanel(scene)
+	backlog.scrollTo(backlog.lastPage())
+}
+
+// HideBacklog closes the backlog panel.
+func HideBacklog() {
+	if backlog == nil {
+		return
+	}
+	backlog.Dispose()
+	backlog = nil
+}
+
+// BacklogShown reports whether the backlog panel is open.
+func BacklogShown() bool { return backlog != nil }
+
+// backlogPanel shows part of the transcript, starting at entry top, with
+// buttons to scroll and to close the panel.
+type backlogPanel struct {
+	*View
+	bubble  *Bubble
+	text    *Text
+	buttons []*Button
+	top     int
+	textBox vec.I2
+}
+
+func newBacklogPanel(scene *Scene) *backlogPanel {
+	bk, _ := game.BubbleKey()
+	p := &backlogPanel{
+		View: &View{},
+		bubble: &Bubble{
+			View: &View{},
+			Key:  bk,
+		},
+		text: &Text{
+			View:     &View{},
+			Font:     game.Font(),
+			BoldFont: gameBoldFont(),
+		},
+	}
+	size := scene.View.Size().Sub(vec.I2{20, 20})
+	p.SetParent(scene.HUD)
+	p.SetPositionAndSize(vec.I2{10, 10}, size)
+	p.SetZ(backlogZ)
+
+	p.bubble.SetParent(p.View)
+	p.bubble.SetSize(size)
+	p.bubble.SetZ(1)
+
+	p.textBox = size.Sub(vec.I2{25, 55})
+	p.text.SetParent(p.bubble.View)
+	p.text.SetPosition(vec.I2{10, 10})
+	p.text.MaxLines = p.textBox.Y / p.text.Font.LineHeight()
+	p.text.SetZ(1)
+
+	pos := vec.I2{10, size.Y - 40}
+	for _, b := range []struct {
+		label  string
+		action func()
+	}{
+		{"Up", func() { p.scrollTo(p.top - 1) }},
+		{"Down", func() { p.scrollTo(p.top + 1) }},
+		{"Close", HideBacklog},
+	} {
+		bounds := vec.Rect{pos, pos.Add(vec.I2{65, 25})}
+		p.buttons = append(p.buttons, NewButton(b.label, b.action, bounds, p.bubble.View))
+		pos.X += 75
+	}
+
+	p.bubble.AddToScene(scene)
+	for _, b := range p.buttons {
+		b.AddToScene(scene)
+	}
+	return p
+}
+
+// lastPage returns the first entry of the last panelful of the transcript.
+func (p *backlogPanel) lastPage() int {
+	h, top := 0, len(transcript)
+	for top > 0 {
+		h += MeasureText(p.text.Font, transcript[top-1].String(), p.textBox.X).Y
+		if h > p.textBox.Y {
+			break
+		}
+		top--
+	}
+	return top
+}
+
+// scrollTo shows entries from top onwards.
+func (p *backlogPanel) scrollTo(top int) {
+	if max := len(transcript) - 1; top > max {
+		top = max
+	}
+	if top < 0 {
+		top = 0
+	}
+	p.top = top
+	lines := make([]string, 0, len(transcript)-top)
+	for _, e := range transcript[top:] {
+		lines = append(lines, e.String())
+	}
+	p.text.Text = strings.Join(lines, "\n")
+	p.text.SetSize(p.textBox)
+	p.text.Layout(true)
+	p.text.AddToScene(scene)
+}
+
+// Handle passes events to the buttons. The panel consumes all events.
+func (p *backlogPanel) Handle(e *Event) bool {
+	switch e.Input {
+	case InputPrev:
+		p.scrollTo(p.top - 1)
+	case InputNext:
+		p.scrollTo(p.top + 1)
+	case InputConfirm, InputCancel:
+		HideBacklog()
+		return true
+	}
+	for _, b := range p.buttons {
+		if b.Handle(e) {
+			break
+		}
+	}
+	return true
+}
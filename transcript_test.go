@@ -0,0 +1,73 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestTranscriptEntryString(t *testing.T) {
+	tests := []struct {
+		e    TranscriptEntry
+		want string
+	}{
+		{TranscriptEntry{Text: "It is dark."}, "It is dark."},
+		{TranscriptEntry{Speaker: "Bob", Text: "Hello!"}, "Bob: Hello!"},
+		{TranscriptEntry{Speaker: "Bob", Text: "Bob: Hello!"}, "Bob: Hello!"},
+		{TranscriptEntry{Text: "Goodbye", Choice: true}, "> Goodbye"},
+	}
+	for _, test := range tests {
+		if got := test.e.String(); got != test.want {
+			t.Errorf("%#v.String() = %q, want %q", test.e, got, test.want)
+		}
+	}
+}
+
+func TestTranscriptLimitAndSave(t *testing.T) {
+	defer func(c *Config) { config = c }(config)
+	config = &Config{TranscriptLimit: 2}
+	ClearTranscript()
+	defer ClearTranscript()
+
+	modelFrame = 1
+	record(TranscriptEntry{Text: "one"})
+	modelFrame = 2
+	spec := &ButtonSpec{Label: "two"}
+	spec.recordedAction()()
+	modelFrame = 3
+	record(TranscriptEntry{Speaker: "Bob", Text: "three"})
+
+	want := []TranscriptEntry{
+		{Frame: 2, Text: "two", Choice: true},
+		{Frame: 3, Speaker: "Bob", Text: "three"},
+	}
+	if got := Transcript(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Transcript() = %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := SaveGame(&buf); err != nil {
+		t.Fatalf("SaveGame: %v", err)
+	}
+	ClearTranscript()
+	if err := LoadGame(&buf); err != nil {
+		t.Fatalf("LoadGame: %v", err)
+	}
+	if got := Transcript(); !reflect.DeepEqual(got, want) {
+		t.Errorf("after LoadGame, Transcript() = %v, want %v", got, want)
+	}
+}
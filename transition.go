@@ -0,0 +1,271 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/DrJosh9000/vec"
+	"github.com/hajimehoshi/ebiten"
+)
+
+// dissolveCell is the size of the squares the dissolve effect covers.
+const dissolveCell = 4
+
+// TransitionEffect is how a transition covers the screen.
+type TransitionEffect int
+
+const (
+	TransitionFade      TransitionEffect = iota // the colour fades in or out
+	TransitionIris                              // a circle closes on, or opens from, Centre
+	TransitionWipe                              // an edge sweeps across the screen in Direction
+	TransitionDissolve                          // squares appear or disappear in a random order
+	TransitionCrossfade                         // the previous scene fades out over the next (see Crossfade)
+)
+
+var transitionEffects = map[string]TransitionEffect{
+	"fade":      TransitionFade,
+	"iris":      TransitionIris,
+	"wipe":      TransitionWipe,
+	"dissolve":  TransitionDissolve,
+	"crossfade": TransitionCrossfade,
+}
+
+// Transition covers the screen with a colour (Out), or uncovers it (not Out),
+// over a number of model updates, drawn over the scene. Transitions carry on
+// while dialogue is shown, when modelFrame doesn't advance, but wait while the
+// backlog is open. Once a transition out finishes, the screen stays covered
+// until the next transition starts.
+type Transition struct {
+	Effect    TransitionEffect
+	Out       bool
+	Frames    int
+	Colour    color.Color // black if nil
+	Centre    vec.I2      // iris centre on the screen; the middle of the screen if zero
+	Direction vec.I2      // wipe direction, such as {-1, 0} for right to left; {1, 0} if zero
+	Done      func()      // called when the transition finishes, if not nil
+
+	frame    int
+	finished bool
+	change   func()        // for crossfades, called on the model update after the scene is captured
+	snapshot *ebiten.Image // for crossfades, the scene before the change
+}
+
+var (
+	transition     *Transition
+	transitionFill *ebiten.Image // white, coloured with ColorM
+)
+
+// StartTransition starts a transition, replacing any other. If the other is a
+// crossfade that hasn't made its change yet, the change is made first.
+func StartTransition(t *Transition) {
+	if old := transition; old != nil {
+		if old.snapshot != nil {
+			old.snapshot.Dispose()
+		}
+		if change := old.change; change != nil {
+			old.change = nil
+			change()
+		}
+	}
+	t.frame, t.finished = 0, false
+	transition = t
+}
+
+// FadeOut fades the screen to black over a number of model updates.
+func FadeOut(frames int, done func()) {
+	StartTransition(&Transition{Effect: TransitionFade, Out: true, Frames: frames, Done: done})
+}
+
+// FadeIn fades the screen in from black over a number of model updates.
+func FadeIn(frames int, done func()) {
+	StartTransition(&Transition{Effect: TransitionFade, Frames: frames, Done: done})
+}
+
+// Crossfade captures the scene as it is next drawn, calls change (to change
+// level, for example) on the following model update, and fades the captured
+// scene out over the changed one.
+func Crossfade(frames int, change, done func()) {
+	StartTransition(&Transition{Effect: TransitionCrossfade, Frames: frames, Done: done, change: change})
+}
+
+// Transitioning reports whether a transition is in progress. The screen may
+// still be covered after a transition out has finished.
+func Transitioning() bool { return transition != nil && !transition.finished }
+
+// updateTransition advances the transition one model update.
+func updateTransition() {
+	t := transition
+	if t == nil || t.finished || (t.Effect == TransitionCrossfade && t.snapshot == nil) {
+		return
+	}
+	if t.change != nil {
+		// The scene was captured while drawing; change it now.
+		change := t.change
+		t.change = nil
+		change()
+		return
+	}
+	if t.frame++; t.frame < t.Frames {
+		return
+	}
+	t.finished = true
+	if !t.Out {
+		if t.snapshot != nil {
+			t.snapshot.Dispose()
+		}
+		transition = nil
+	}
+	if t.Done != nil {
+		t.Done()
+	}
+}
+
+// coverage is how much of the screen is covered, from 0 to 1.
+func (t *Transition) coverage() float64 {
+	c := 1.0
+	if t.Frames > 0 && t.frame < t.Frames {
+		c = float64(t.frame) / float64(t.Frames)
+	}
+	if t.Effect == TransitionCrossfade || !t.Out {
+		c = 1 - c
+	}
+	return c
+}
+
+// cover returns the rectangles covering c of a screen of the given size.
+func (t *Transition) cover(c float64, size vec.I2) rectParts {
+	switch t.Effect {
+	case TransitionIris:
+		ctr := t.Centre
+		if ctr == (vec.I2{}) {
+			ctr = size.Div(2)
+		}
+		// The radius that uncovers the furthest corner.
+		far := vec.F2{
+			math.Max(float64(ctr.X), float64(size.X-ctr.X)),
+			math.Max(float64(ctr.Y), float64(size.Y-ctr.Y)),
+		}
+		r := (1 - c) * math.Sqrt(far.Dot(far))
+		var rs rectParts
+		for y := 0; y < size.Y; y++ {
+			dy := float64(y) + 0.5 - float64(ctr.Y)
+			if math.Abs(dy) >= r {
+				rs = append(rs, vec.NewRect(0, y, size.X, y+1))
+				continue
+			}
+			h := math.Sqrt(r*r - dy*dy)
+			x0, x1 := int(math.Floor(float64(ctr.X)-h+0.5)), int(math.Floor(float64(ctr.X)+h+0.5))
+			if x0 > 0 {
+				rs = append(rs, vec.NewRect(0, y, x0, y+1))
+			}
+			if x1 < size.X {
+				rs = append(rs, vec.NewRect(x1, y, size.X, y+1))
+			}
+		}
+		return rs
+
+	case TransitionWipe:
+		d := t.Direction
+		if d == (vec.I2{}) {
+			d = vec.I2{1, 0}
+		}
+		w, h := int(c*float64(size.X)+0.5), int(c*float64(size.Y)+0.5)
+		switch {
+		case d.X > 0:
+			return rectParts{vec.NewRect(0, 0, w, size.Y)}
+		case d.X < 0:
+			return rectParts{vec.NewRect(size.X-w, 0, size.X, size.Y)}
+		case d.Y > 0:
+			return rectParts{vec.NewRect(0, 0, size.X, h)}
+		}
+		return rectParts{vec.NewRect(0, size.Y-h, size.X, size.Y)}
+
+	case TransitionDissolve:
+		cells := vec.I2{(size.X + dissolveCell - 1) / dissolveCell, (size.Y + dissolveCell - 1) / dissolveCell}
+		n := cells.X * cells.Y
+		// Always the same order for the same size, so it doesn't flicker.
+		order := rand.New(rand.NewSource(int64(n))).Perm(n)
+		rs := make(rectParts, 0, n)
+		for _, i := range order[:int(c*float64(n)+0.5)] {
+			ul := vec.I2{i % cells.X, i / cells.X}.Mul(dissolveCell)
+			rs = append(rs, vec.Rect{UL: ul, DR: ul.Add(vec.I2{dissolveCell, dissolveCell}).ClampHi(size)})
+		}
+		return rs
+	}
+	return rectParts{{DR: size}}
+}
+
+// drawTransition draws the transition over the scene.
+func drawTransition(screen *ebiten.Image) error {
+	t := transition
+	if t == nil {
+		return nil
+	}
+	size := vec.NewI2(screen.Size())
+	c := t.coverage()
+
+	if t.Effect == TransitionCrossfade {
+		if t.snapshot == nil {
+			s, err := ebiten.NewImage(size.X, size.Y, ebiten.FilterNearest)
+			if err != nil {
+				return fmt.Errorf("creating crossfade image: %v", err)
+			}
+			if err := scene.Draw(s); err != nil {
+				return err
+			}
+			t.snapshot = s
+		}
+		op := &ebiten.DrawImageOptions{}
+		op.ColorM.Scale(1, 1, 1, c)
+		return screen.DrawImage(t.snapshot, op)
+	}
+
+	if transitionFill == nil {
+		f, err := ebiten.NewImage(1, 1, ebiten.FilterNearest)
+		if err != nil {
+			return fmt.Errorf("creating transition image: %v", err)
+		}
+		if err := f.Fill(color.White); err != nil {
+			return err
+		}
+		transitionFill = f
+	}
+	cl := color.NRGBA{0, 0, 0, 0xff}
+	if t.Colour != nil {
+		cl = color.NRGBAModel.Convert(t.Colour).(color.NRGBA)
+	}
+	alpha := float64(cl.A) / 0xff
+	if t.Effect == TransitionFade {
+		alpha *= c
+	}
+	rs := t.cover(c, size)
+	if len(rs) == 0 || alpha == 0 {
+		return nil
+	}
+	op := &ebiten.DrawImageOptions{ImageParts: rs}
+	op.ColorM.Scale(float64(cl.R)/0xff, float64(cl.G)/0xff, float64(cl.B)/0xff, alpha)
+	return screen.DrawImage(transitionFill, op)
+}
+
+// rectParts draws a one-pixel image over each rectangle.
+type rectParts []vec.Rect
+
+func (r rectParts) Len() int                       { return len(r) }
+func (r rectParts) Dst(i int) (x0, y0, x1, y1 int) { return r[i].C() }
+func (r rectParts) Src(int) (x0, y0, x1, y1 int)   { return 0, 0, 1, 1 }
@@ -0,0 +1,174 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DrJosh9000/vec"
+	"github.com/hajimehoshi/ebiten"
+)
+
+// coveredArea is the total area of the rectangles.
+func coveredArea(rs rectParts) int {
+	a := 0
+	for _, r := range rs {
+		sz := r.Size()
+		a += sz.X * sz.Y
+	}
+	return a
+}
+
+func TestTransitionCover(t *testing.T) {
+	size := vec.I2{64, 48}
+	all := size.X * size.Y
+	for _, eff := range []TransitionEffect{TransitionFade, TransitionIris, TransitionWipe, TransitionDissolve} {
+		tr := &Transition{Effect: eff}
+		if got := coveredArea(tr.cover(1, size)); got != all {
+			t.Errorf("effect %d: area covered at 1 = %d, want %d", eff, got, all)
+		}
+		if eff == TransitionFade {
+			continue // covers everything, with varying alpha
+		}
+		if got := coveredArea(tr.cover(0, size)); got != 0 {
+			t.Errorf("effect %d: area covered at 0 = %d, want 0", eff, got)
+		}
+		if got := coveredArea(tr.cover(0.5, size)); got <= 0 || got >= all {
+			t.Errorf("effect %d: area covered at 0.5 = %d, want between 0 and %d", eff, got, all)
+		}
+	}
+
+	wipes := []struct {
+		dir  vec.I2
+		want vec.Rect
+	}{
+		{vec.I2{}, vec.NewRect(0, 0, 16, 48)},
+		{vec.I2{-1, 0}, vec.NewRect(48, 0, 64, 48)},
+		{vec.I2{0, 1}, vec.NewRect(0, 0, 64, 12)},
+		{vec.I2{0, -1}, vec.NewRect(0, 36, 64, 48)},
+	}
+	for _, w := range wipes {
+		tr := &Transition{Effect: TransitionWipe, Direction: w.dir}
+		if got := tr.cover(0.25, size); len(got) != 1 || got[0] != w.want {
+			t.Errorf("wipe %v at 0.25 covers %v, want %v", w.dir, got, w.want)
+		}
+	}
+}
+
+func TestTransitionUpdate(t *testing.T) {
+	defer func() { transition = nil }()
+
+	done := 0
+	FadeOut(4, func() { done++ })
+	if got, want := transition.coverage(), 0.0; got != want {
+		t.Errorf("FadeOut coverage at start = %v, want %v", got, want)
+	}
+	for i := 0; i < 3; i++ {
+		updateTransition()
+	}
+	if !Transitioning() || done != 0 {
+		t.Errorf("after 3 of 4 frames: Transitioning() = %t, done = %d; want true, 0", Transitioning(), done)
+	}
+	updateTransition()
+	if Transitioning() || done != 1 {
+		t.Errorf("after 4 frames: Transitioning() = %t, done = %d; want false, 1", Transitioning(), done)
+	}
+	if transition == nil || transition.coverage() != 1 {
+		t.Errorf("after FadeOut finished, the screen isn't covered")
+	}
+
+	FadeIn(2, nil)
+	updateTransition()
+	if got, want := transition.coverage(), 0.5; got != want {
+		t.Errorf("FadeIn coverage after 1 of 2 frames = %v, want %v", got, want)
+	}
+	updateTransition()
+	if transition != nil {
+		t.Errorf("after FadeIn finished, transition = %v, want nil", transition)
+	}
+}
+
+func TestCrossfadeChangesOnModelUpdate(t *testing.T) {
+	defer func() { transition = nil }()
+
+	changed := 0
+	Crossfade(2, func() { changed++ }, nil)
+	updateTransition()
+	if changed != 0 || transition.frame != 0 {
+		t.Errorf("before the scene is captured: changed %d, frame %d; want 0, 0", changed, transition.frame)
+	}
+
+	// As drawTransition captures it; never drawn or disposed here.
+	transition.snapshot = new(ebiten.Image)
+	if changed != 0 {
+		t.Errorf("changed %d times when captured, want 0", changed)
+	}
+	updateTransition()
+	if changed != 1 || transition.coverage() != 1 {
+		t.Errorf("on the next model update: changed %d, coverage %v; want 1, 1", changed, transition.coverage())
+	}
+	updateTransition()
+	if changed != 1 || transition.coverage() != 0.5 {
+		t.Errorf("one frame into the fade: changed %d, coverage %v; want 1, 0.5", changed, transition.coverage())
+	}
+}
+
+func TestTransitionReplacesCrossfade(t *testing.T) {
+	defer func() { transition = nil }()
+
+	changed := 0
+	Crossfade(2, func() { changed++ }, nil)
+	FadeIn(2, nil)
+	if changed != 1 {
+		t.Errorf("after replacing a pending crossfade, changed %d times, want 1", changed)
+	}
+	FadeOut(2, nil)
+	if changed != 1 {
+		t.Errorf("after replacing the fade, changed %d times, want 1", changed)
+	}
+}
+
+func TestTransitionAction(t *testing.T) {
+	defer func(c *Config, tbn map[string]*Trigger, v map[string]string) {
+		config, triggersByName, vars = c, tbn, v
+	}(config, triggersByName, vars)
+	config = &Config{}
+	defer func() { transition = nil }()
+
+	const src = `[
+		{"name": "dark", "on_demand": true, "actions": [{"type": "transition", "effect": "iris", "out": true, "frames": 2, "then": "lit"}]},
+		{"name": "lit", "on_demand": true, "actions": [{"type": "set", "var": "lit", "value": "yes"}]}
+	]`
+	trigs, err := LoadTriggers(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("LoadTriggers: %v", err)
+	}
+	triggersByName = make(map[string]*Trigger)
+	for _, tr := range trigs {
+		triggersByName[tr.Name] = tr
+	}
+	vars = make(map[string]string)
+
+	trigs[0].Fire(0)
+	if transition == nil || transition.Effect != TransitionIris || !transition.Out {
+		t.Fatalf("after firing dark, transition = %+v, want an iris out", transition)
+	}
+	updateTransition()
+	updateTransition()
+	if got, want := Var("lit"), "yes"; got != want {
+		t.Errorf("after the transition, lit = %q, want %q", got, want)
+	}
+}
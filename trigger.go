@@ -14,22 +14,245 @@
 
 package awakengine
 
-import "github.com/DrJosh9000/vec"
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/DrJosh9000/vec"
+)
 
 // Trigger is everything to do with reacting to the player or time or ...
 // On the PC entering any of the Tiles, Fired, Active, and Depends will be
 // checked and then Fire will happen.
 // If no Tiles are listed, it will be added to a global list of triggers
 // checked on every frame.
+//
+// Timers: if After names another trigger, the trigger can only fire Delay model
+// frames or more after that trigger fired (and, if repeating, once each time it
+// fires). If Every is positive, the trigger can only fire once every Every
+// frames. Cooldown is the minimum number of frames between firings of a repeating
+// trigger. Model frames don't advance while dialogue is shown, so timers pause
+// during dialogue. Timer state is included by SaveGame.
+//
+// OnDemand triggers are never checked automatically, and only fire when
+// requested with FireTrigger (for example, by a Hotspot).
+//
+// Triggers are checked in order of descending Priority; triggers with equal
+// Priority are checked in the order provided by Game.Triggers. By default at
+// most one trigger in a list fires per frame (see Config.FireAllTriggers).
 type Trigger struct {
-	Name    string
-	Tiles   []vec.I2
-	Active  func(gameFrame int) bool
-	Depends []string
-	Fire    func(gameFrame int)
-	Repeat  bool
-
-	fired bool
+	Name     string
+	Tiles    []vec.I2
+	Active   func(gameFrame int) bool
+	Depends  []string
+	Fire     func(gameFrame int)
+	Repeat   bool
+	Priority int
+
+	After    string
+	Delay    int
+	Every    int
+	Cooldown int
+
+	OnDemand bool
+
+	// Then names triggers that firing this one leads to firing later (for
+	// example, when a transition finishes). They are checked by validation and
+	// shown in the trigger graph.
+	Then []string
+
+	fired   bool
+	firedAt int // model frame of the most recent firing
+}
+
+func (t *Trigger) Reset() { t.fired, t.firedAt = false, 0 }
+
+// deps returns the names of all the triggers this trigger depends on.
+func (t *Trigger) deps() []string {
+	if t.After == "" {
+		return t.Depends
+	}
+	return append(t.Depends[:len(t.Depends):len(t.Depends)], t.After)
+}
+
+// eligible reports whether the trigger can fire now.
+func (t *Trigger) eligible() bool {
+	if t.fired && !t.Repeat {
+		return false
+	}
+	if t.Active != nil && !t.Active(modelFrame) {
+		return false
+	}
+	// All dependencies fired?
+	for _, dep := range t.Depends {
+		if !triggersByName[dep].fired {
+			return false
+		}
+	}
+	// Timers expired?
+	if t.fired && modelFrame-t.firedAt < t.Cooldown {
+		return false
+	}
+	if t.Every > 0 && modelFrame-t.firedAt < t.Every {
+		return false
+	}
+	if t.After != "" {
+		after := triggersByName[t.After]
+		if !after.fired {
+			return false
+		}
+		due := after.firedAt + t.Delay
+		if modelFrame < due || (t.fired && t.firedAt >= due) {
+			return false
+		}
+	}
+	return true
+}
+
+// FireTrigger fires the named trigger if it is eligible to fire, and returns
+// true if it fired.
+func FireTrigger(name string) bool {
+	t := triggersByName[name]
+	if t == nil {
+		if config.Debug {
+			log.Printf("FireTrigger: no trigger named %q", name)
+		}
+		return false
+	}
+	if !t.eligible() {
+		return false
+	}
+	if config.Debug {
+		log.Printf("firing %q on demand", name)
+	}
+	t.fire()
+	return true
+}
+
+// fire fires the trigger.
+func (t *Trigger) fire() {
+	if t.Fire != nil {
+		t.Fire(modelFrame)
+	}
+	t.fired = true
+	t.firedAt = modelFrame
+}
+
+// byPriority sorts triggers by descending priority.
+type byPriority []*Trigger
+
+func (b byPriority) Len() int           { return len(b) }
+func (b byPriority) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byPriority) Less(i, j int) bool { return b[i].Priority > b[j].Priority }
+
+// sortTriggers sorts triggers into the order they are checked: by descending
+// priority, preserving the existing order among triggers of equal priority.
+func sortTriggers(trigs []*Trigger) { sort.Stable(byPriority(trigs)) }
+
+// validateTriggers checks that trigger names are unique, that all dependencies
+// (and Then) refer to known triggers, and that there are no dependency cycles
+// (triggers in a cycle could never fire).
+func validateTriggers(trigs []*Trigger) error {
+	byName := make(map[string]*Trigger, len(trigs))
+	names := make(map[string]bool, len(trigs))
+	for i, t := range trigs {
+		if t.Name == "" {
+			return fmt.Errorf("trigger %d has no name", i)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("trigger %d: duplicate name %q", i, t.Name)
+		}
+		byName[t.Name] = t
+		names[t.Name] = true
+	}
+	for _, t := range trigs {
+		for _, dep := range t.deps() {
+			if !names[dep] {
+				return unknownDepError(t.Name, dep, names)
+			}
+		}
+		for _, then := range t.Then {
+			if !names[then] {
+				return fmt.Errorf("trigger %q: then names unknown trigger %q", t.Name, then)
+			}
+		}
+	}
+
+	// Depth-first search for cycles. Triggers on the current path are "visiting";
+	// triggers not yet reached have no state.
+	const (
+		visiting = iota + 1
+		done
+	)
+	state := make(map[string]int, len(trigs))
+	var path []string
+	var visit func(t *Trigger) error
+	visit = func(t *Trigger) error {
+		switch state[t.Name] {
+		case visiting:
+			start := 0
+			for path[start] != t.Name {
+				start++
+			}
+			cycle := append(path[start:len(path):len(path)], t.Name)
+			return fmt.Errorf("trigger dependency cycle: %s", strings.Join(cycle, " -> "))
+		case done:
+			return nil
+		}
+		state[t.Name] = visiting
+		path = append(path, t.Name)
+		for _, dep := range t.deps() {
+			if err := visit(byName[dep]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[t.Name] = done
+		return nil
+	}
+	for _, t := range trigs {
+		if err := visit(t); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (t *Trigger) Reset() { t.fired = false }
+// WriteTriggerGraph writes the trigger dependency graph in Graphviz DOT format.
+// Each edge points from a dependency to the trigger that depends on it. Global
+// triggers are drawn as boxes, repeating triggers are dashed, and timer edges
+// (After) are dotted. Then edges point from a trigger to the triggers it leads
+// to, and are bold.
+func WriteTriggerGraph(w io.Writer, trigs []*Trigger) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph triggers {")
+	for _, t := range trigs {
+		var attrs []string
+		if len(t.Tiles) == 0 {
+			attrs = append(attrs, "shape=box")
+		} else {
+			attrs = append(attrs, fmt.Sprintf("tooltip=\"%d tiles\"", len(t.Tiles)))
+		}
+		if t.Repeat {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(bw, "\t%q [%s];\n", t.Name, strings.Join(attrs, ", "))
+	}
+	for _, t := range trigs {
+		for _, dep := range t.Depends {
+			fmt.Fprintf(bw, "\t%q -> %q;\n", dep, t.Name)
+		}
+		if t.After != "" {
+			fmt.Fprintf(bw, "\t%q -> %q [label=\"after %d\", style=dotted];\n", t.After, t.Name, t.Delay)
+		}
+		for _, then := range t.Then {
+			fmt.Fprintf(bw, "\t%q -> %q [label=\"then\", style=bold];\n", t.Name, then)
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
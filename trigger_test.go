@@ -0,0 +1,202 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DrJosh9000/vec"
+)
+
+func TestValidateTriggers(t *testing.T) {
+	tests := []struct {
+		trigs []*Trigger
+		want  string // substring of the error, or "" for no error
+	}{
+		{
+			trigs: []*Trigger{{Name: "a"}, {Name: "b", Depends: []string{"a"}}},
+		},
+		{
+			trigs: []*Trigger{{Name: "a"}, {}},
+			want:  "trigger 1 has no name",
+		},
+		{
+			trigs: []*Trigger{{Name: "a"}, {Name: "b", Depends: []string{"c"}}},
+			want:  `"b" depends on unknown trigger "c"`,
+		},
+		{
+			trigs: []*Trigger{{Name: "a", Then: []string{"z"}}},
+			want:  `"a": then names unknown trigger "z"`,
+		},
+		{
+			trigs: []*Trigger{{Name: "a", Then: []string{"a"}}}, // not a dependency, so not a cycle
+		},
+		{
+			trigs: []*Trigger{{Name: "a", Depends: []string{"a"}}},
+			want:  "cycle: a -> a",
+		},
+		{
+			trigs: []*Trigger{
+				{Name: "start"},
+				{Name: "a", Depends: []string{"start", "c"}},
+				{Name: "b", Depends: []string{"a"}},
+				{Name: "c", Depends: []string{"b"}},
+			},
+			want: "cycle: a -> c -> b -> a",
+		},
+	}
+	for i, test := range tests {
+		err := validateTriggers(test.trigs)
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("test %d: validateTriggers error = %v, want nil", i, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("test %d: validateTriggers error = %v, want error containing %q", i, err, test.want)
+		}
+	}
+}
+
+func TestWriteTriggerGraph(t *testing.T) {
+	trigs := []*Trigger{
+		{Name: "a"},
+		{Name: "b", Tiles: []vec.I2{{1, 1}}, Depends: []string{"a"}, Repeat: true, Then: []string{"a"}},
+	}
+	var buf bytes.Buffer
+	if err := WriteTriggerGraph(&buf, trigs); err != nil {
+		t.Fatalf("WriteTriggerGraph: %v", err)
+	}
+	want := `digraph triggers {
+	"a" [shape=box];
+	"b" [tooltip="1 tiles", style=dashed];
+	"a" -> "b";
+	"b" -> "a" [label="then", style=bold];
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteTriggerGraph wrote:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// testTriggers makes triggers that record the order they fired in.
+func testTriggers(order *[]string, trigs ...*Trigger) []*Trigger {
+	triggersByName = make(map[string]*Trigger)
+	for _, t := range trigs {
+		t := t
+		t.Fire = func(int) { *order = append(*order, t.Name) }
+		triggersByName[t.Name] = t
+	}
+	sortTriggers(trigs)
+	return trigs
+}
+
+func TestEvaluateTriggersOrder(t *testing.T) {
+	config = &Config{}
+	var order []string
+	trigs := testTriggers(&order,
+		&Trigger{Name: "low", Priority: -1},
+		&Trigger{Name: "first"},
+		&Trigger{Name: "high", Priority: 10},
+		&Trigger{Name: "second"},
+	)
+	for i := 0; i < 5; i++ {
+		evaluateTriggers(trigs)
+	}
+	if got, want := strings.Join(order, " "), "high first second low"; got != want {
+		t.Errorf("Got firing order %q, want %q", got, want)
+	}
+}
+
+func TestEvaluateTriggersFireAll(t *testing.T) {
+	config = &Config{FireAllTriggers: true}
+	var order []string
+	trigs := testTriggers(&order,
+		&Trigger{Name: "needs_later", Depends: []string{"later"}},
+		&Trigger{Name: "a"},
+		&Trigger{Name: "later", Priority: -1},
+		&Trigger{Name: "needs_a", Depends: []string{"a"}},
+	)
+	if !evaluateTriggers(trigs) {
+		t.Errorf("evaluateTriggers = false, want true")
+	}
+	if got, want := strings.Join(order, " "), "a needs_a later"; got != want {
+		t.Errorf("After one pass, got firing order %q, want %q", got, want)
+	}
+	order = nil
+	evaluateTriggers(trigs)
+	if got, want := strings.Join(order, " "), "needs_later"; got != want {
+		t.Errorf("After two passes, got firing order %q, want %q", got, want)
+	}
+	order = nil
+	if evaluateTriggers(trigs) {
+		t.Errorf("evaluateTriggers with nothing to fire = true, want false")
+	}
+}
+
+func TestTriggerTimers(t *testing.T) {
+	config = &Config{FireAllTriggers: true}
+	var order []string
+	trigs := testTriggers(&order,
+		&Trigger{Name: "door"},
+		&Trigger{Name: "creak", After: "door", Delay: 3},
+		&Trigger{Name: "tick", Every: 4, Repeat: true},
+		&Trigger{Name: "cool", Cooldown: 5, Repeat: true},
+	)
+	fired := make(map[string][]int)
+	run := func(from, to int) {
+		for modelFrame = from; modelFrame < to; modelFrame++ {
+			order = nil
+			evaluateTriggers(trigs)
+			for _, n := range order {
+				fired[n] = append(fired[n], modelFrame)
+			}
+		}
+	}
+	run(0, 6)
+
+	// Save and restore partway through; timers should carry on as before.
+	var buf bytes.Buffer
+	if err := SaveGame(&buf); err != nil {
+		t.Fatalf("SaveGame: %v", err)
+	}
+	for _, trig := range trigs {
+		trig.Reset()
+	}
+	modelFrame = 0
+	if err := LoadGame(&buf); err != nil {
+		t.Fatalf("LoadGame: %v", err)
+	}
+	if got, want := modelFrame, 6; got != want {
+		t.Errorf("After LoadGame, got modelFrame %d, want %d", got, want)
+	}
+	run(6, 13)
+
+	want := map[string][]int{
+		"door":  {0},
+		"creak": {3},
+		"tick":  {4, 8, 12},
+		"cool":  {0, 5, 10},
+	}
+	for n, w := range want {
+		if got := fired[n]; !reflect.DeepEqual(got, w) {
+			t.Errorf("Trigger %q fired on frames %v, want %v", n, got, w)
+		}
+	}
+}
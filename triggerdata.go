@@ -0,0 +1,293 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/DrJosh9000/vec"
+)
+
+// TriggerDef is the data file representation of a Trigger. A list of these can be
+// loaded from JSON with LoadTriggers, for example:
+//
+//	[
+//	  {
+//	    "name": "meet_bob",
+//	    "region": [3, 4, 6, 5],
+//	    "condition": "!met_bob",
+//	    "actions": [
+//	      {"type": "dialogue", "lines": [{"text": "Hi, I'm Bob."}]},
+//	      {"type": "set", "var": "met_bob", "value": "yes"}
+//	    ]
+//	  }
+//	]
+type TriggerDef struct {
+	Name      string      `json:"name"`
+	Tiles     [][2]int    `json:"tiles,omitempty"`  // tile coordinates as [x, y]
+	Region    []int       `json:"region,omitempty"` // tile rectangle as [x0, y0, x1, y1], x1 & y1 exclusive
+	Depends   []string    `json:"depends,omitempty"`
+	Repeat    bool        `json:"repeat,omitempty"`
+	Priority  int         `json:"priority,omitempty"`
+	After     string      `json:"after,omitempty"`
+	Delay     int         `json:"delay,omitempty"`
+	Every     int         `json:"every,omitempty"`
+	Cooldown  int         `json:"cooldown,omitempty"`
+	OnDemand  bool        `json:"on_demand,omitempty"`
+	Condition string      `json:"condition,omitempty"` // see Var and SetVar
+	Actions   []ActionDef `json:"actions,omitempty"`
+}
+
+// ActionDef describes a built-in action to perform when a data-driven trigger fires.
+// Which fields are used depends on the Type:
+//
+//	"dialogue": Lines are pushed with PushDialogueToBack.
+//	"set":      Var is set to Value with SetVar.
+//	"level":    Level is loaded with ChangeLevel.
+//	"anim":     The sprite registered as Sprite jumps to Frame.
+//	"transition": A Transition with the Effect ("fade", "iris", "wipe",
+//	            "dissolve" or "crossfade") runs for Frames, Out or in. A
+//	            crossfade changes to Level, if given. When it finishes, the
+//	            trigger named Then (if any) is fired with FireTrigger.
+type ActionDef struct {
+	Type   string    `json:"type"`
+	Lines  []LineDef `json:"lines,omitempty"`
+	Var    string    `json:"var,omitempty"`
+	Value  string    `json:"value,omitempty"`
+	Level  string    `json:"level,omitempty"`
+	Sprite string    `json:"sprite,omitempty"`
+	Frame  int       `json:"frame,omitempty"`
+	Effect string    `json:"effect,omitempty"`
+	Frames int       `json:"frames,omitempty"`
+	Out    bool      `json:"out,omitempty"`
+	Then   string    `json:"then,omitempty"`
+}
+
+// LineDef is the data file representation of a DialogueLine.
+type LineDef struct {
+	Text     string `json:"text"`
+	AutoNext bool   `json:"auto_next,omitempty"`
+	Slowness int    `json:"slowness,omitempty"`
+}
+
+// LoadTriggers reads a JSON list of TriggerDefs and converts them into Triggers.
+// Dependencies must name other triggers in the same list. Only JSON is read;
+// trigger files written in YAML must be converted to JSON first.
+func LoadTriggers(r io.Reader) ([]*Trigger, error) {
+	var defs []*TriggerDef
+	if err := json.NewDecoder(r).Decode(&defs); err != nil {
+		return nil, fmt.Errorf("decoding triggers: %v", err)
+	}
+	return TriggersFromDefs(defs)
+}
+
+// TriggersFromDefs converts TriggerDefs into Triggers, validating them along the way.
+func TriggersFromDefs(defs []*TriggerDef) ([]*Trigger, error) {
+	trigs := make([]*Trigger, 0, len(defs))
+	for _, d := range defs {
+		t, err := d.trigger()
+		if err != nil {
+			return nil, fmt.Errorf("trigger %q: %v", d.Name, err)
+		}
+		trigs = append(trigs, t)
+	}
+	if err := validateTriggers(trigs); err != nil {
+		return nil, err
+	}
+	return trigs, nil
+}
+
+func (d *TriggerDef) trigger() (*Trigger, error) {
+	t := &Trigger{
+		Name:     d.Name,
+		Depends:  d.Depends,
+		Repeat:   d.Repeat,
+		Priority: d.Priority,
+		After:    d.After,
+		Delay:    d.Delay,
+		Every:    d.Every,
+		Cooldown: d.Cooldown,
+		OnDemand: d.OnDemand,
+	}
+	for _, p := range d.Tiles {
+		t.Tiles = append(t.Tiles, vec.I2{p[0], p[1]})
+	}
+	if d.Region != nil {
+		if len(d.Region) != 4 {
+			return nil, fmt.Errorf("region has %d numbers, want 4", len(d.Region))
+		}
+		x0, y0, x1, y1 := d.Region[0], d.Region[1], d.Region[2], d.Region[3]
+		if x1 <= x0 || y1 <= y0 {
+			return nil, fmt.Errorf("region %v is empty", d.Region)
+		}
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				t.Tiles = append(t.Tiles, vec.I2{x, y})
+			}
+		}
+	}
+	if d.Condition != "" {
+		cond, err := parseCondition(d.Condition)
+		if err != nil {
+			return nil, err
+		}
+		t.Active = func(int) bool { return cond() }
+	}
+	acts := make([]func() error, 0, len(d.Actions))
+	for i := range d.Actions {
+		if then := d.Actions[i].Then; then != "" {
+			t.Then = append(t.Then, then)
+		}
+		a, err := d.Actions[i].action()
+		if err != nil {
+			return nil, fmt.Errorf("action %d: %v", i, err)
+		}
+		acts = append(acts, a)
+	}
+	name := d.Name
+	t.Fire = func(int) {
+		for i, a := range acts {
+			if err := a(); err != nil {
+				log.Printf("trigger %q: action %d: %v", name, i, err)
+			}
+		}
+	}
+	return t, nil
+}
+
+func (a *ActionDef) action() (func() error, error) {
+	switch a.Type {
+	case "dialogue":
+		if len(a.Lines) == 0 {
+			return nil, fmt.Errorf("dialogue action has no lines")
+		}
+		lines := a.Lines
+		return func() error {
+			dl := make([]*DialogueLine, 0, len(lines))
+			for _, l := range lines {
+				dl = append(dl, &DialogueLine{
+					Text:     l.Text,
+					AutoNext: l.AutoNext,
+					Slowness: l.Slowness,
+				})
+			}
+			PushDialogueToBack(dl...)
+			return nil
+		}, nil
+	case "set":
+		if !validVarName(a.Var) {
+			return nil, fmt.Errorf("set action has invalid variable name %q", a.Var)
+		}
+		k, v := a.Var, a.Value
+		return func() error {
+			SetVar(k, v)
+			return nil
+		}, nil
+	case "level":
+		if a.Level == "" {
+			return nil, fmt.Errorf("level action has no level")
+		}
+		l := a.Level
+		return func() error { return ChangeLevel(l) }, nil
+	case "anim":
+		if a.Sprite == "" {
+			return nil, fmt.Errorf("anim action has no sprite")
+		}
+		n, f := a.Sprite, a.Frame
+		return func() error {
+			s, ok := sprites[n]
+			if !ok {
+				return fmt.Errorf("no sprite registered as %q", n)
+			}
+			s.SetFrame(f)
+			return nil
+		}, nil
+	case "transition":
+		eff, ok := transitionEffects[a.Effect]
+		if !ok {
+			return nil, fmt.Errorf("transition action has unknown effect %q", a.Effect)
+		}
+		t := Transition{Effect: eff, Out: a.Out, Frames: a.Frames}
+		l, then := a.Level, a.Then
+		return func() error {
+			t := t
+			if then != "" {
+				t.Done = func() { FireTrigger(then) }
+			}
+			if eff != TransitionCrossfade {
+				StartTransition(&t)
+				return nil
+			}
+			var change func()
+			if l != "" {
+				change = func() {
+					if err := ChangeLevel(l); err != nil {
+						log.Printf("transition action: %v", err)
+					}
+				}
+			}
+			Crossfade(t.Frames, change, t.Done)
+			return nil
+		}, nil
+	case "":
+		return nil, fmt.Errorf("action has no type")
+	}
+	return nil, fmt.Errorf("unknown action type %q", a.Type)
+}
+
+// unknownDepError reports an unknown dependency, suggesting the most similar
+// known trigger name if there is a plausible one.
+func unknownDepError(trig, dep string, names map[string]bool) error {
+	best, bestDist := "", len(dep)/2+1
+	for n := range names {
+		if d := editDistance(dep, n); d < bestDist || (d == bestDist && n < best) {
+			best, bestDist = n, d
+		}
+	}
+	if best != "" {
+		return fmt.Errorf("trigger %q depends on unknown trigger %q (did you mean %q?)", trig, dep, best)
+	}
+	return fmt.Errorf("trigger %q depends on unknown trigger %q", trig, dep)
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			c := prev[j-1]
+			if a[i-1] != b[j-1] {
+				c++
+			}
+			if x := prev[j] + 1; x < c {
+				c = x
+			}
+			if x := cur[j-1] + 1; x < c {
+				c = x
+			}
+			cur[j] = c
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
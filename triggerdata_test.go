@@ -0,0 +1,85 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DrJosh9000/vec"
+)
+
+func TestLoadTriggers(t *testing.T) {
+	const src = `[
+		{"name": "a", "tiles": [[1, 2]]},
+		{"name": "b", "region": [0, 0, 2, 2], "depends": ["a"], "condition": "door == open && !locked",
+		 "actions": [{"type": "set", "var": "seen_b", "value": "yes"}]}
+	]`
+	trigs, err := LoadTriggers(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("LoadTriggers: %v", err)
+	}
+	if got, want := len(trigs), 2; got != want {
+		t.Fatalf("Got %d triggers, want %d", got, want)
+	}
+	if got, want := trigs[0].Tiles, []vec.I2{{1, 2}}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("Got a.Tiles %v, want %v", got, want)
+	}
+	if got, want := len(trigs[1].Tiles), 4; got != want {
+		t.Errorf("Got %d tiles in b's region, want %d", got, want)
+	}
+
+	b := trigs[1]
+	vars = map[string]string{"door": "open"}
+	if !b.Active(0) {
+		t.Errorf("b.Active with door open and not locked = false, want true")
+	}
+	SetVar("locked", "yes")
+	if b.Active(0) {
+		t.Errorf("b.Active with door locked = true, want false")
+	}
+	b.Fire(0)
+	if got, want := Var("seen_b"), "yes"; got != want {
+		t.Errorf("After firing b, got seen_b %q, want %q", got, want)
+	}
+}
+
+func TestLoadTriggersErrors(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`[{"tiles": [[0, 0]]}]`, "trigger 0 has no name"},
+		{`[{"name": "a"}, {"name": "a"}]`, `duplicate name "a"`},
+		{`[{"name": "door_open"}, {"name": "b", "depends": ["dor_open"]}]`, `unknown trigger "dor_open" (did you mean "door_open"?)`},
+		{`[{"name": "b", "depends": ["zzz"]}]`, `unknown trigger "zzz"`},
+		{`[{"name": "a", "region": [0, 0, 1]}]`, "region has 3 numbers"},
+		{`[{"name": "a", "condition": "x =="}]`, "missing value after =="},
+		{`[{"name": "a", "condition": "x y"}]`, "invalid variable name"},
+		{`[{"name": "a", "actions": [{"type": "explode"}]}]`, `unknown action type "explode"`},
+		{`[{"name": "a", "actions": [{"type": "dialogue"}]}]`, "no lines"},
+		{`[{"name": "a", "actions": [{"type": "transition", "effect": "spin"}]}]`, `unknown effect "spin"`},
+		{`[{"name": "a", "actions": [{"type": "transition", "effect": "fade", "then": "b"}]}]`, `"a": then names unknown trigger "b"`},
+	}
+	for _, test := range tests {
+		_, err := LoadTriggers(strings.NewReader(test.src))
+		if err == nil {
+			t.Errorf("LoadTriggers(%s) error = nil, want error containing %q", test.src, test.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("LoadTriggers(%s) error = %v, want error containing %q", test.src, err, test.want)
+		}
+	}
+}
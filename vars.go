@@ -0,0 +1,119 @@
+// Copyright 2016 Josh Deprez
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awakengine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// vars holds game variables, which are used by conditions in data-driven
+// triggers and set by their actions.
+var vars = make(map[string]string)
+
+// Var returns the value of a game variable, or "" if it is not set.
+func Var(name string) string { return vars[name] }
+
+// SetVar sets a game variable. Setting a variable to "" unsets it.
+func SetVar(name, value string) {
+	if value == "" {
+		delete(vars, name)
+		return
+	}
+	vars[name] = value
+}
+
+// parseCondition parses a condition on game variables into a func that evaluates it.
+// A condition is a list of clauses separated by "&&", and each clause is one of:
+//
+//	name            true if the variable is set
+//	!name           true if the variable is not set
+//	name == value   true if the variable equals value
+//	name != value   true if the variable does not equal value
+//
+// Values may be quoted with double quotes. An empty condition is always true.
+func parseCondition(cond string) (func() bool, error) {
+	var clauses []func() bool
+	for _, c := range strings.Split(cond, "&&") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			if strings.TrimSpace(cond) == "" {
+				break
+			}
+			return nil, fmt.Errorf("empty clause in condition %q", cond)
+		}
+		cl, err := parseClause(c)
+		if err != nil {
+			return nil, fmt.Errorf("condition %q: %v", cond, err)
+		}
+		clauses = append(clauses, cl)
+	}
+	return func() bool {
+		for _, cl := range clauses {
+			if !cl() {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func parseClause(c string) (func() bool, error) {
+	for _, op := range []string{"==", "!="} {
+		i := strings.Index(c, op)
+		if i < 0 {
+			continue
+		}
+		name, val := strings.TrimSpace(c[:i]), strings.TrimSpace(c[i+len(op):])
+		if !validVarName(name) {
+			return nil, fmt.Errorf("invalid variable name %q", name)
+		}
+		if val == "" {
+			return nil, fmt.Errorf("missing value after %s", op)
+		}
+		if strings.HasPrefix(val, `"`) {
+			v, err := strconv.Unquote(val)
+			if err != nil {
+				return nil, fmt.Errorf("bad quoted value %s: %v", val, err)
+			}
+			val = v
+		}
+		if op == "==" {
+			return func() bool { return vars[name] == val }, nil
+		}
+		return func() bool { return vars[name] != val }, nil
+	}
+	neg := strings.HasPrefix(c, "!")
+	name := strings.TrimSpace(strings.TrimPrefix(c, "!"))
+	if !validVarName(name) {
+		return nil, fmt.Errorf("invalid variable name %q", name)
+	}
+	return func() bool { return (vars[name] != "") != neg }, nil
+}
+
+func validVarName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -174,6 +174,16 @@ func (v *View) removeFromParent() {
 	}
 }
 
+// within reports whether v is a, or a descendant of a.
+func (v *View) within(a *View) bool {
+	for ; v != nil; v = v.parent {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
+
 func (v *View) SetParent(parent *View) {
 	if parent == v.parent {
 		return